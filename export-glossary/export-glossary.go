@@ -0,0 +1,373 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// export-glossary compiles the glossary, one YAML file per term in
+// _data/glossary, into files that tools outside of Jekyll can read:
+//
+//	glossary.json    the terms, their related terms and the canonical tags
+//	glossary.jsonld  the terms as a SKOS vocabulary in JSON-LD
+//	glossary.csv     the names and descriptions of the terms, for translators
+//
+// Related terms are resolved in both directions and the terms are grouped by
+// the tags of _data/canonical-tags. Related terms and tags that do not exist
+// are reported and left out, see TestGlossaryRelations and
+// TestCanonicalTags.
+//
+// Usage, from the root of the website repository:
+//
+//	go run export-glossary/export-glossary.go -out dir
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	examples "k8s.io/website/test"
+)
+
+var (
+	glossaryDir = flag.String("glossary", "_data/glossary", "Directory of the glossary terms.")
+	tagsDir     = flag.String("tags", "_data/canonical-tags", "Directory of the canonical tags of the glossary terms.")
+	outDir      = flag.String("out", "", "Directory the exported files are written to. Required, since Jekyll would publish them in the website.")
+	siteURL     = flag.String("site", "https://kubernetes.io", "Address of the website, for the links to the terms.")
+)
+
+// glossaryHome is the page of the website that lists every term, see
+// _plugins/glossary_tags.rb.
+const glossaryHome = "/docs/reference/glossary/?all=true"
+
+// skosContext is the JSON-LD context of the SKOS vocabulary.
+var skosContext = map[string]string{
+	"skos": "http://www.w3.org/2004/02/skos/core#",
+	"rdfs": "http://www.w3.org/2000/01/rdf-schema#",
+}
+
+// tooltipRegexp matches the glossary tags of descriptions, which are
+// replaced with their text, or with the name of their term.
+var tooltipRegexp = regexp.MustCompile(`\{%-?\s*glossary_tooltip\s([^%]*)-?%\}`)
+
+var tooltipParameterRegexp = regexp.MustCompile(`(\w+)=(?:"([^"]*)"|'([^']*)')`)
+
+// term is a glossary term in glossary.json.
+type term struct {
+	Id   string   `json:"id"`
+	Name string   `json:"name"`
+	URL  string   `json:"url"`
+	Aka  []string `json:"aka,omitempty"`
+	Tags []string `json:"tags"`
+	// Related are the terms that this term relates to.
+	Related []string `json:"related,omitempty"`
+	// RelatedBy are the terms that relate to this term.
+	RelatedBy        []string `json:"relatedBy,omitempty"`
+	ShortDescription string   `json:"shortDescription"`
+	LongDescription  string   `json:"longDescription,omitempty"`
+}
+
+// tag is a canonical tag in glossary.json, with the terms that use it.
+type tag struct {
+	Id          string   `json:"id"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Terms       []string `json:"terms"`
+}
+
+// glossary is the content of glossary.json.
+type glossary struct {
+	Terms []*term `json:"terms"`
+	Tags  []*tag  `json:"tags"`
+}
+
+// skosLabel is a literal in English.
+type skosLabel struct {
+	Value    string `json:"@value"`
+	Language string `json:"@language"`
+}
+
+// skosRef is a reference to another node of the vocabulary.
+type skosRef struct {
+	Id string `json:"@id"`
+}
+
+// skosNode is a concept scheme, a concept or a collection of concepts.
+type skosNode struct {
+	Id         string      `json:"@id"`
+	Type       string      `json:"@type"`
+	PrefLabel  skosLabel   `json:"skos:prefLabel"`
+	AltLabels  []skosLabel `json:"skos:altLabel,omitempty"`
+	Definition *skosLabel  `json:"skos:definition,omitempty"`
+	ScopeNote  *skosLabel  `json:"skos:scopeNote,omitempty"`
+	InScheme   *skosRef    `json:"skos:inScheme,omitempty"`
+	Related    []skosRef   `json:"skos:related,omitempty"`
+	Members    []skosRef   `json:"skos:member,omitempty"`
+	SeeAlso    *skosRef    `json:"rdfs:seeAlso,omitempty"`
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s -out dir\n\nExports the glossary to glossary.json, glossary.jsonld and glossary.csv in dir.\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *outDir == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	files, err := examples.LoadGlossary(*glossaryDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read the glossary: %v\n", err)
+		os.Exit(1)
+	}
+	tags, err := examples.LoadCanonicalTags(*tagsDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to read the canonical tags: %v\n", err)
+		os.Exit(1)
+	}
+	g := compile(files, tags)
+
+	sort.Slice(files, func(i, j int) bool { return examples.GlossaryID(files[i].Path) < examples.GlossaryID(files[j].Path) })
+	failed := false
+	for _, export := range []struct {
+		name  string
+		write func(string) error
+	}{
+		{"glossary.json", func(path string) error { return writeJSON(path, g) }},
+		{"glossary.jsonld", func(path string) error { return writeJSON(path, skos(g)) }},
+		{"glossary.csv", func(path string) error { return writeCSV(path, files) }},
+	} {
+		path := filepath.Join(*outDir, export.name)
+		if err := export.write(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to write %s: %v\n", path, err)
+			failed = true
+			continue
+		}
+		fmt.Fprintf(os.Stdout, "Wrote %s\n", path)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// compile resolves the related terms and tags of the terms of files, in both
+// directions. Terms are identified by the GlossaryID of their file, like
+// pages and related terms do. References to terms or tags that do not exist
+// and files whose id is already used by another file are reported and
+// dropped.
+func compile(files []examples.GlossaryFile, tags []examples.CanonicalTag) *glossary {
+	g := &glossary{}
+	byID := map[string]*term{}
+	names := map[string]string{}
+	var terms []examples.GlossaryFile
+	for _, f := range files {
+		id := examples.GlossaryID(f.Path)
+		if _, found := byID[id]; found {
+			fmt.Fprintf(os.Stderr, "%s: skipping term, its id %q is used by another file, see TestGlossaryRelations\n", f.Path, id)
+			continue
+		}
+		byID[id] = &term{Id: id, Name: f.Term.Name}
+		names[id] = f.Term.Name
+		terms = append(terms, f)
+	}
+	for _, f := range terms {
+		compiled := byID[examples.GlossaryID(f.Path)]
+		compiled.URL = termURL(f)
+		compiled.Aka = f.Term.Aka
+		compiled.Tags = []string{}
+		compiled.ShortDescription = plainText(f.Term.ShortDescription, names)
+		compiled.LongDescription = plainText(f.Term.LongDescription, names)
+		g.Terms = append(g.Terms, compiled)
+	}
+	tagsByID := map[string]*tag{}
+	for _, t := range tags {
+		compiled := &tag{Id: t.Id, Name: t.Name, Description: t.Description, Terms: []string{}}
+		g.Tags = append(g.Tags, compiled)
+		tagsByID[t.Id] = compiled
+	}
+
+	for _, f := range terms {
+		compiled := byID[examples.GlossaryID(f.Path)]
+		for _, id := range f.Term.Related {
+			related, found := byID[id]
+			if !found || id == compiled.Id {
+				fmt.Fprintf(os.Stderr, "%s: skipping related term %q, see TestGlossaryRelations\n", f.Path, id)
+				continue
+			}
+			compiled.Related = append(compiled.Related, id)
+			related.RelatedBy = append(related.RelatedBy, compiled.Id)
+		}
+		for _, id := range f.Term.Tags {
+			tag, found := tagsByID[id]
+			if !found {
+				fmt.Fprintf(os.Stderr, "%s: skipping tag %q, which is not a canonical tag\n", f.Path, id)
+				continue
+			}
+			compiled.Tags = append(compiled.Tags, id)
+			tag.Terms = append(tag.Terms, compiled.Id)
+		}
+	}
+
+	sort.Slice(g.Terms, func(i, j int) bool { return g.Terms[i].Id < g.Terms[j].Id })
+	for _, t := range g.Terms {
+		sort.Strings(t.RelatedBy)
+	}
+	sort.Slice(g.Tags, func(i, j int) bool { return g.Tags[i].Id < g.Tags[j].Id })
+	for _, t := range g.Tags {
+		sort.Strings(t.Terms)
+	}
+	return g
+}
+
+// termURL returns the address of the documentation of the term of f, or of
+// its entry in the glossary if it has none. The glossary anchors an entry on
+// the id of the term, see _plugins/glossary_tags.rb.
+func termURL(f examples.GlossaryFile) string {
+	link := f.Term.FullLink
+	if link == "" {
+		id := f.Term.Id
+		if id == "" {
+			id = examples.GlossaryID(f.Path)
+		}
+		link = glossaryHome + "#term-" + id
+	}
+	if strings.HasPrefix(link, "/") {
+		link = strings.TrimSuffix(*siteURL, "/") + link
+	}
+	return link
+}
+
+// plainText returns description without Liquid tags, as the website renders
+// it in tooltips: glossary tooltips are replaced with their text. Markdown is
+// kept.
+func plainText(description string, names map[string]string) string {
+	text := tooltipRegexp.ReplaceAllStringFunc(description, func(tooltip string) string {
+		params := map[string]string{}
+		for _, param := range tooltipParameterRegexp.FindAllStringSubmatch(tooltip, -1) {
+			params[param[1]] = param[2] + param[3]
+		}
+		if text, found := params["text"]; found {
+			return text
+		}
+		return names[params["term_id"]]
+	})
+	return strings.TrimSpace(html.UnescapeString(text))
+}
+
+// skos returns g as a SKOS concept scheme, with a concept per term and a
+// collection per tag. skos:related is symmetric, so it includes the terms
+// that relate to each term.
+func skos(g *glossary) interface{} {
+	scheme := strings.TrimSuffix(*siteURL, "/") + glossaryHome
+	conceptID := func(id string) string {
+		return scheme + "#term-" + id
+	}
+	english := func(value string) skosLabel {
+		return skosLabel{Value: value, Language: "en"}
+	}
+	nodes := []*skosNode{{
+		Id:        scheme,
+		Type:      "skos:ConceptScheme",
+		PrefLabel: english("Kubernetes Glossary"),
+	}}
+	for _, t := range g.Terms {
+		definition := english(t.ShortDescription)
+		node := &skosNode{
+			Id:         conceptID(t.Id),
+			Type:       "skos:Concept",
+			PrefLabel:  english(t.Name),
+			Definition: &definition,
+			InScheme:   &skosRef{Id: scheme},
+			SeeAlso:    &skosRef{Id: t.URL},
+		}
+		for _, aka := range t.Aka {
+			node.AltLabels = append(node.AltLabels, english(aka))
+		}
+		if t.LongDescription != "" {
+			note := english(t.LongDescription)
+			node.ScopeNote = &note
+		}
+		related := map[string]bool{}
+		for _, id := range append(append([]string{}, t.Related...), t.RelatedBy...) {
+			related[id] = true
+		}
+		var ids []string
+		for id := range related {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			node.Related = append(node.Related, skosRef{Id: conceptID(id)})
+		}
+		nodes = append(nodes, node)
+	}
+	for _, t := range g.Tags {
+		definition := english(t.Description)
+		node := &skosNode{
+			Id:         scheme + "#tag-" + t.Id,
+			Type:       "skos:Collection",
+			PrefLabel:  english(t.Name),
+			Definition: &definition,
+		}
+		for _, id := range t.Terms {
+			node.Members = append(node.Members, skosRef{Id: conceptID(id)})
+		}
+		nodes = append(nodes, node)
+	}
+	return map[string]interface{}{
+		"@context": skosContext,
+		"@graph":   nodes,
+	}
+}
+
+// writeJSON writes v to path as indented JSON.
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// writeCSV writes the ids, names, aliases and descriptions of the terms of
+// files to path, with empty columns for their translation. Descriptions are
+// kept as they are in the glossary files, with their Liquid tags, so that
+// translations can be copied back into them.
+func writeCSV(path string, files []examples.GlossaryFile) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"id", "name", "aka", "short-description", "long-description", "translated-name", "translated-aka", "translated-short-description", "translated-long-description"})
+	for _, f := range files {
+		t := f.Term
+		w.Write([]string{examples.GlossaryID(f.Path), t.Name, strings.Join(t.Aka, "; "), strings.TrimSpace(t.ShortDescription), strings.TrimSpace(t.LongDescription), "", "", "", ""})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
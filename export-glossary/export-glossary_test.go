@@ -0,0 +1,108 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	examples "k8s.io/website/test"
+)
+
+func TestCompile(t *testing.T) {
+	files := []examples.GlossaryFile{
+		{Path: "glossary/container.yaml", Term: examples.GlossaryTerm{Id: "container", Name: "Container", Tags: []string{"fundamental"}}},
+		// Pages refer to the term by the name of its file.
+		{Path: "glossary/node.yml", Term: examples.GlossaryTerm{Id: "worker", Name: "Node", Related: []string{"pod"}}},
+		{Path: "glossary/pod.yaml", Term: examples.GlossaryTerm{
+			Id:               "pod",
+			Name:             "Pod",
+			FullLink:         "/docs/concepts/workloads/pods/pod-overview/",
+			Related:          []string{"container", "missing", "pod"},
+			Tags:             []string{"fundamental", "missing"},
+			ShortDescription: `A group of {% glossary_tooltip term_id="container" %}s.`,
+		}},
+		// Uses the id of pod.yaml, and is dropped.
+		{Path: "glossary/pod.yml", Term: examples.GlossaryTerm{Id: "pod", Name: "Duplicate"}},
+	}
+	tags := []examples.CanonicalTag{
+		{Id: "fundamental", Name: "Fundamental"},
+		{Id: "networking", Name: "Networking"},
+	}
+
+	expected := &glossary{
+		Terms: []*term{
+			{
+				Id:        "container",
+				Name:      "Container",
+				URL:       "https://kubernetes.io/docs/reference/glossary/?all=true#term-container",
+				Tags:      []string{"fundamental"},
+				RelatedBy: []string{"pod"},
+			},
+			{
+				Id:      "node",
+				Name:    "Node",
+				URL:     "https://kubernetes.io/docs/reference/glossary/?all=true#term-worker",
+				Tags:    []string{},
+				Related: []string{"pod"},
+			},
+			{
+				Id:               "pod",
+				Name:             "Pod",
+				URL:              "https://kubernetes.io/docs/concepts/workloads/pods/pod-overview/",
+				Tags:             []string{"fundamental"},
+				Related:          []string{"container"},
+				RelatedBy:        []string{"node"},
+				ShortDescription: "A group of Containers.",
+			},
+		},
+		Tags: []*tag{
+			{Id: "fundamental", Name: "Fundamental", Terms: []string{"container", "pod"}},
+			{Id: "networking", Name: "Networking", Terms: []string{}},
+		},
+	}
+	actual := compile(files, tags)
+	if !reflect.DeepEqual(actual.Terms, expected.Terms) {
+		for i := range actual.Terms {
+			t.Logf("term %d: %+v", i, *actual.Terms[i])
+		}
+		t.Errorf("Unexpected terms")
+	}
+	if !reflect.DeepEqual(actual.Tags, expected.Tags) {
+		for i := range actual.Tags {
+			t.Logf("tag %d: %+v", i, *actual.Tags[i])
+		}
+		t.Errorf("Unexpected tags")
+	}
+}
+
+func TestPlainText(t *testing.T) {
+	names := map[string]string{"pod": "Pod", "container": "Container"}
+	cases := []struct {
+		description string
+		expected    string
+	}{
+		{`A {% glossary_tooltip text="Pod" term_id="pod" %} runs.`, "A Pod runs."},
+		{`{% glossary_tooltip term_id='container' %}s run in a pod.`, "Containers run in a pod."},
+		{"\n  Kept as Markdown, `code` &amp; all.\n", "Kept as Markdown, `code` & all."},
+	}
+	for _, c := range cases {
+		if actual := plainText(c.description, names); actual != c.expected {
+			t.Errorf("Expected %q for %q, got %q", c.expected, c.description, actual)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// migrate-examples rewrites the example manifests of the documentation that
+// use a deprecated API version (see examples.DeprecatedAPIs) to its stable
+// replacement.
+//
+// Files are edited in place, line by line, so that comments, key order and
+// formatting are preserved. Kinds that need spec.selector in their new API
+// version get one built from the pod template labels. Every migrated file is
+// decoded again and must result in the intended objects. Changes that cannot
+// be made automatically, e.g. a kind that was replaced by another one or a
+// spec written on a single line, are reported and left for a human, like the
+// code blocks of Markdown pages that use a deprecated API version.
+//
+// Usage, from the root of the website repository:
+//
+//	go run migrate-examples/migrate-examples.go [-dry-run] [dir ...]
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	examples "k8s.io/website/test"
+)
+
+var dryRun = flag.Bool("dry-run", false, "Only print the changes, do not write any file.")
+
+// kindsRequiringSelector are the kinds whose spec.selector is defaulted from
+// the pod template labels in the beta API versions but is required in apps/v1.
+var kindsRequiringSelector = map[string]bool{
+	"DaemonSet":   true,
+	"Deployment":  true,
+	"ReplicaSet":  true,
+	"StatefulSet": true,
+}
+
+// edit is a change to one line of a file. Either the first occurrence of old
+// at or after column is replaced with new, or insert is inserted before the
+// line.
+type edit struct {
+	line   int
+	column int
+	old    string
+	new    string
+	insert []string
+}
+
+// note describes a change, or a problem that needs to be fixed by hand.
+type note struct {
+	// line is the 1-based line number the note is about.
+	line int
+	text string
+}
+
+// selectorNote is the note about a selector added by addSelector.
+const selectorNote = "added spec.selector from the pod template labels"
+
+// document is a YAML document of a file.
+type document struct {
+	// line is the 0-based index of the first line of the document in the
+	// file.
+	line  int
+	lines []string
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-dry-run] [dir ...]\n\nMigrates the examples in dir (default: docs) to current API versions.\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	dirs := flag.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"docs"}
+	}
+
+	failed := false
+	for _, dir := range dirs {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			ext := filepath.Ext(path)
+			if info.IsDir() || (ext != ".yaml" && ext != ".json" && ext != ".md") {
+				return nil
+			}
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if ext == ".md" {
+				for _, n := range codeBlockNotes(data) {
+					fmt.Fprintf(os.Stdout, "%s:%d: %s\n", path, n.line, n.text)
+				}
+				return nil
+			}
+			out, notes, err := migrate(data)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+				failed = true
+				return nil
+			}
+			for _, n := range notes {
+				fmt.Fprintf(os.Stdout, "%s:%d: %s\n", path, n.line, n.text)
+			}
+			if *dryRun || bytes.Equal(out, data) {
+				return nil
+			}
+			return ioutil.WriteFile(path, out, info.Mode())
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error when walking %s: %v\n", dir, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// migrate returns data with every document that uses a deprecated API
+// version migrated to its replacement, and notes describing each change or
+// each problem that needs to be fixed by hand. Documents that are not
+// mappings with apiVersion and kind are left untouched. It fails if the
+// result does not decode to the migrated objects.
+func migrate(data []byte) ([]byte, []note, error) {
+	var (
+		edits    []edit
+		notes    []note
+		expected []interface{}
+	)
+	lines := strings.Split(string(data), "\n")
+	for _, doc := range splitDocuments(lines) {
+		var obj interface{}
+		if err := yaml.Unmarshal([]byte(strings.Join(doc.lines, "\n")), &obj); err != nil {
+			return nil, nil, err
+		}
+		expected = append(expected, obj)
+		if _, isMap := obj.(map[interface{}]interface{}); !isMap {
+			continue
+		}
+		// A second time to keep the order of the labels.
+		var ordered yaml.MapSlice
+		if err := yaml.Unmarshal([]byte(strings.Join(doc.lines, "\n")), &ordered); err != nil {
+			return nil, nil, err
+		}
+		e, n := migrateObject(doc, ordered, obj.(map[interface{}]interface{}))
+		edits = append(edits, e...)
+		notes = append(notes, n...)
+	}
+	if len(edits) == 0 {
+		return data, notes, nil
+	}
+	out := applyEdits(lines, edits)
+	for i, doc := range splitDocuments(out) {
+		var obj interface{}
+		if err := yaml.Unmarshal([]byte(strings.Join(doc.lines, "\n")), &obj); err != nil {
+			return nil, nil, fmt.Errorf("migrated document %d does not decode, migrate it by hand: %v", i, err)
+		}
+		if i >= len(expected) || !reflect.DeepEqual(obj, expected[i]) {
+			return nil, nil, fmt.Errorf("migrated document %d does not decode to the migrated object, migrate it by hand", i)
+		}
+	}
+	return []byte(strings.Join(out, "\n")), notes, nil
+}
+
+// splitDocuments splits lines into YAML documents at the "---" separators,
+// like the YAML reader of the tests.
+func splitDocuments(lines []string) []document {
+	docs := []document{{}}
+	for i, line := range lines {
+		if strings.HasPrefix(line, "---") && strings.TrimSpace(line[3:]) == "" {
+			docs = append(docs, document{line: i + 1})
+			continue
+		}
+		docs[len(docs)-1].lines = append(docs[len(docs)-1].lines, line)
+	}
+	return docs
+}
+
+// migrateObject returns the edits that migrate ordered, the object of doc,
+// and notes about them. obj is the same object, which is updated to the
+// result of the edits.
+func migrateObject(doc document, ordered yaml.MapSlice, obj map[interface{}]interface{}) ([]edit, []note) {
+	apiVersion, _ := obj["apiVersion"].(string)
+	kind, _ := obj["kind"].(string)
+	api, found := examples.FindDeprecatedAPI(apiVersion, kind)
+	if !found {
+		return nil, nil
+	}
+	line, column, found := findKey(doc.lines, 0, "apiVersion")
+	if !found {
+		return nil, []note{{doc.line + 1, fmt.Sprintf("%s %s must be migrated to %s by hand", apiVersion, kind, api.Replacement)}}
+	}
+	if api.ReplacementKind != "" {
+		return nil, []note{{doc.line + line + 1, fmt.Sprintf("%s %s must be migrated to %s %s by hand", apiVersion, kind, api.Replacement, api.ReplacementKind)}}
+	}
+
+	edits := []edit{{
+		line:   doc.line + line,
+		column: column,
+		old:    apiVersion,
+		new:    api.Replacement,
+	}}
+	notes := []note{{doc.line + line + 1, fmt.Sprintf("%s %s -> %s", apiVersion, kind, api.Replacement)}}
+	if kindsRequiringSelector[kind] && api.Replacement == "apps/v1" {
+		e, n, problem := addSelector(doc, ordered, obj)
+		if problem != "" {
+			return nil, []note{{doc.line + line + 1, fmt.Sprintf("%s %s must be migrated to %s by hand: %s, add spec.selector by hand", apiVersion, kind, api.Replacement, problem)}}
+		}
+		if e != nil {
+			edits = append(edits, *e)
+			notes = append(notes, n)
+		}
+	}
+	obj["apiVersion"] = api.Replacement
+	return edits, notes
+}
+
+// addSelector returns the edit that adds spec.selector.matchLabels to the
+// object of doc, built from the labels of the pod template, right before
+// spec.template and with its indentation. It returns no edit if the object
+// already has a selector, and a problem if the selector cannot be added
+// without rewriting other lines. The selector is added to obj too.
+func addSelector(doc document, ordered yaml.MapSlice, obj map[interface{}]interface{}) (*edit, note, string) {
+	spec, isMap := obj["spec"].(map[interface{}]interface{})
+	if !isMap {
+		return nil, note{}, "object has no spec"
+	}
+	if _, found := spec["selector"]; found {
+		return nil, note{}, ""
+	}
+	var labels yaml.MapSlice
+	if template, isMap := mapSliceValue(mapSliceValue(ordered, "spec"), "template").(yaml.MapSlice); isMap {
+		labels, _ = mapSliceValue(mapSliceValue(template, "metadata"), "labels").(yaml.MapSlice)
+	}
+	if len(labels) == 0 {
+		return nil, note{}, "pod template has no labels"
+	}
+	specLine, specColumn, found := findKey(doc.lines, 0, "spec")
+	if !found {
+		return nil, note{}, "spec is not on a line of its own"
+	}
+	value := keyValue(doc.lines[specLine])
+	if value != "" && value != "{" {
+		return nil, note{}, "spec is written on a single line"
+	}
+	templateLine, templateColumn, found := findKey(doc.lines, specLine+1, "template")
+	if !found || templateColumn <= specColumn {
+		return nil, note{}, "spec.template is not on a line of its own"
+	}
+
+	indent := strings.Repeat(" ", templateColumn)
+	var lines []string
+	if value == "{" {
+		// A flow mapping, e.g. JSON.
+		var pairs []string
+		for _, label := range labels {
+			pairs = append(pairs, strconv.Quote(fmt.Sprint(label.Key))+": "+strconv.Quote(fmt.Sprint(label.Value)))
+		}
+		lines = []string{indent + `"selector": {"matchLabels": {` + strings.Join(pairs, ", ") + `}},`}
+	} else {
+		selector, err := yaml.Marshal(yaml.MapSlice{{Key: "selector", Value: yaml.MapSlice{{Key: "matchLabels", Value: labels}}}})
+		if err != nil {
+			return nil, note{}, err.Error()
+		}
+		// yaml.Marshal indents by two spaces, use the same indentation
+		// step as the file.
+		step := strings.Repeat(" ", templateColumn-specColumn)
+		for _, line := range strings.Split(strings.TrimSuffix(string(selector), "\n"), "\n") {
+			trimmed := strings.TrimLeft(line, " ")
+			lines = append(lines, indent+strings.Repeat(step, (len(line)-len(trimmed))/2)+trimmed)
+		}
+	}
+
+	matchLabels := map[interface{}]interface{}{}
+	for _, label := range labels {
+		matchLabels[label.Key] = label.Value
+	}
+	spec["selector"] = map[interface{}]interface{}{"matchLabels": matchLabels}
+	return &edit{line: doc.line + templateLine, insert: lines}, note{doc.line + templateLine + 1, selectorNote}, ""
+}
+
+// findKey returns the 0-based line and column of key in lines, starting at
+// line from, where key is the first token of the line. If key occurs several
+// times, the least indented occurrence, which is the closest to the root of
+// the document, is returned.
+func findKey(lines []string, from int, key string) (int, int, bool) {
+	line, column := -1, 0
+	for i := from; i < len(lines); i++ {
+		trimmed := strings.TrimLeft(lines[i], " \t")
+		name := trimmed
+		if j := strings.Index(trimmed, ":"); j >= 0 {
+			name = strings.Trim(strings.TrimSpace(trimmed[:j]), `"'`)
+		}
+		if name != key || !strings.Contains(trimmed, ":") {
+			continue
+		}
+		if indent := len(lines[i]) - len(trimmed); line < 0 || indent < column {
+			line, column = i, indent
+		}
+	}
+	return line, column, line >= 0
+}
+
+// keyValue returns the value after the key of line, without its comment.
+func keyValue(line string) string {
+	value := line[strings.Index(line, ":")+1:]
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value)
+}
+
+// mapSliceValue returns the value of key in m, if m is a yaml.MapSlice.
+func mapSliceValue(m interface{}, key string) interface{} {
+	items, _ := m.(yaml.MapSlice)
+	for _, item := range items {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+// applyEdits applies edits to lines, starting with the last line so that the
+// line numbers of the remaining edits stay valid.
+func applyEdits(lines []string, edits []edit) []string {
+	lines = append([]string{}, lines...)
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].line != edits[j].line {
+			return edits[i].line > edits[j].line
+		}
+		// Replace within a line before inserting lines in front of it.
+		return edits[i].insert == nil && edits[j].insert != nil
+	})
+	for _, e := range edits {
+		if e.insert != nil {
+			lines = append(lines[:e.line], append(e.insert, lines[e.line:]...)...)
+			continue
+		}
+		line := lines[e.line]
+		if i := strings.Index(line[e.column:], e.old); i >= 0 {
+			i += e.column
+			lines[e.line] = line[:i] + e.new + line[i+len(e.old):]
+		}
+	}
+	return lines
+}
+
+// codeBlockNotes returns a note for each yaml or json code block of the
+// Markdown page in data that uses a deprecated API version. Pages often show
+// old API versions on purpose, e.g. to explain a migration, so code blocks
+// are left for a human.
+func codeBlockNotes(data []byte) []note {
+	var notes []note
+	for _, block := range examples.ExtractCodeBlocks(data) {
+		if block.Lang != "yaml" && block.Lang != "yml" && block.Lang != "json" {
+			continue
+		}
+		// Blocks that do not decode, e.g. with elided content, are skipped.
+		_, blockNotes, err := migrate([]byte(block.Content))
+		if err != nil {
+			continue
+		}
+		for _, n := range blockNotes {
+			if n.text == selectorNote {
+				continue
+			}
+			notes = append(notes, note{block.Line + n.line, "code block not migrated: " + n.text})
+		}
+	}
+	return notes
+}
@@ -0,0 +1,251 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		expected string
+		notes    []note
+	}{
+		{
+			name: "selector from the pod template labels",
+			input: `# An nginx Deployment.
+apiVersion: extensions/v1beta1 # deprecated
+kind: Deployment
+metadata:
+  name: nginx
+spec:
+  replicas: 2
+  template:
+    metadata:
+      labels:
+        tier: frontend
+        app: nginx
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.7.9
+`,
+			expected: `# An nginx Deployment.
+apiVersion: apps/v1 # deprecated
+kind: Deployment
+metadata:
+  name: nginx
+spec:
+  replicas: 2
+  selector:
+    matchLabels:
+      tier: frontend
+      app: nginx
+  template:
+    metadata:
+      labels:
+        tier: frontend
+        app: nginx
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.7.9
+`,
+			notes: []note{{2, "extensions/v1beta1 Deployment -> apps/v1"}, {8, selectorNote}},
+		},
+		{
+			name: "indentation of the file",
+			input: `apiVersion: apps/v1beta2
+kind: ReplicaSet
+metadata:
+    name: frontend
+spec:
+    template:
+        metadata:
+            labels:
+                app: guestbook
+`,
+			expected: `apiVersion: apps/v1
+kind: ReplicaSet
+metadata:
+    name: frontend
+spec:
+    selector:
+        matchLabels:
+            app: guestbook
+    template:
+        metadata:
+            labels:
+                app: guestbook
+`,
+			notes: []note{{1, "apps/v1beta2 ReplicaSet -> apps/v1"}, {6, selectorNote}},
+		},
+		{
+			name: "JSON",
+			input: `{
+  "apiVersion": "extensions/v1beta1",
+  "kind": "Deployment",
+  "metadata": {"name": "nginx"},
+  "spec": {
+    "template": {
+      "metadata": {"labels": {"app": "nginx"}},
+      "spec": {"containers": [{"name": "nginx", "image": "nginx:1.7.9"}]}
+    }
+  }
+}`,
+			expected: `{
+  "apiVersion": "apps/v1",
+  "kind": "Deployment",
+  "metadata": {"name": "nginx"},
+  "spec": {
+    "selector": {"matchLabels": {"app": "nginx"}},
+    "template": {
+      "metadata": {"labels": {"app": "nginx"}},
+      "spec": {"containers": [{"name": "nginx", "image": "nginx:1.7.9"}]}
+    }
+  }
+}`,
+			notes: []note{{2, "extensions/v1beta1 Deployment -> apps/v1"}, {6, selectorNote}},
+		},
+		{
+			name: "multiple documents",
+			input: `apiVersion: v1
+kind: Service
+metadata:
+  name: fluentd
+---
+apiVersion: extensions/v1beta1
+kind: DaemonSet
+metadata:
+  name: fluentd
+spec:
+  selector:
+    matchLabels:
+      name: fluentd
+  template:
+    metadata:
+      labels:
+        name: fluentd
+---
+apiVersion: rbac.authorization.k8s.io/v1beta1
+kind: ClusterRole
+metadata:
+  name: fluentd
+`,
+			expected: `apiVersion: v1
+kind: Service
+metadata:
+  name: fluentd
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: fluentd
+spec:
+  selector:
+    matchLabels:
+      name: fluentd
+  template:
+    metadata:
+      labels:
+        name: fluentd
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: fluentd
+`,
+			notes: []note{
+				{6, "extensions/v1beta1 DaemonSet -> apps/v1"},
+				{19, "rbac.authorization.k8s.io/v1beta1 ClusterRole -> rbac.authorization.k8s.io/v1"},
+			},
+		},
+		{
+			name: "spec on a single line",
+			input: `apiVersion: apps/v1beta1
+kind: Deployment
+metadata: {name: nginx}
+spec: {template: {metadata: {labels: {app: nginx}}, spec: {containers: [{name: nginx, image: nginx}]}}}
+`,
+			expected: `apiVersion: apps/v1beta1
+kind: Deployment
+metadata: {name: nginx}
+spec: {template: {metadata: {labels: {app: nginx}}, spec: {containers: [{name: nginx, image: nginx}]}}}
+`,
+			notes: []note{{1, "apps/v1beta1 Deployment must be migrated to apps/v1 by hand: spec is written on a single line, add spec.selector by hand"}},
+		},
+		{
+			name: "replaced kind",
+			input: `apiVersion: extensions/v1beta1
+kind: ThirdPartyResource
+metadata:
+  name: cron-tab.stable.example.com
+`,
+			expected: `apiVersion: extensions/v1beta1
+kind: ThirdPartyResource
+metadata:
+  name: cron-tab.stable.example.com
+`,
+			notes: []note{{1, "extensions/v1beta1 ThirdPartyResource must be migrated to apiextensions.k8s.io/v1beta1 CustomResourceDefinition by hand"}},
+		},
+		{
+			name: "current API version",
+			input: `apiVersion: v1
+kind: Pod
+metadata:
+  name: nginx
+`,
+			expected: `apiVersion: v1
+kind: Pod
+metadata:
+  name: nginx
+`,
+		},
+	}
+	for _, c := range cases {
+		out, notes, err := migrate([]byte(c.input))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.name, err)
+			continue
+		}
+		if string(out) != c.expected {
+			t.Errorf("%s: expected\n%s\ngot\n%s", c.name, c.expected, out)
+		}
+		if !reflect.DeepEqual(notes, c.notes) {
+			t.Errorf("%s: expected notes %v, got %v", c.name, c.notes, notes)
+		}
+	}
+}
+
+func TestCodeBlockNotes(t *testing.T) {
+	markdown := "Deployment:\n\n" +
+		"```yml\napiVersion: rbac.authorization.k8s.io/v1beta1\nkind: Role\nmetadata:\n  name: reader\n```\n\n" +
+		"```json\n{\"apiVersion\": \"apps/v1\", \"kind\": \"Deployment\"}\n```\n\n" +
+		"  ```yaml\n  apiVersion: batch/v2alpha1\n  kind: CronJob\n  ```\n\n" +
+		"Blocks that do not decode are skipped:\n\n" +
+		"```yaml\napiVersion: apps/v1beta2\nkind: [Deployment\n```\n"
+	expected := []note{
+		{4, "code block not migrated: rbac.authorization.k8s.io/v1beta1 Role -> rbac.authorization.k8s.io/v1"},
+		{15, "code block not migrated: batch/v2alpha1 CronJob -> batch/v1beta1"},
+	}
+	if actual := codeBlockNotes([]byte(markdown)); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
@@ -0,0 +1,326 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package examples_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var validationCacheFile = flag.String("validation-cache", filepath.Join(os.TempDir(), "k8s-website-validation-cache.json"),
+	"Skip the examples and pages that passed without warnings in an earlier run of the same test binary and flags, and did not change since. Empty disables the cache.")
+
+// outputFlags only change where the results of the tests are written, so
+// they are not part of the keys of the validation cache.
+var outputFlags = map[string]bool{
+	"validation-cache": true,
+	"report-json":      true,
+	"report-junit":     true,
+}
+
+// validationCacheData is the content of the validation cache file.
+type validationCacheData struct {
+	// Binary is the hash of the test binary that wrote the file. The cache
+	// is discarded when the tests change.
+	Binary string `json:"binary"`
+	// Passed are the hashes of the files that passed, see
+	// validationCacheKey.
+	Passed []string `json:"passed"`
+}
+
+// validationCache records the files that passed their tests without any
+// finding, so that skipping them loses no warning.
+var validationCache struct {
+	sync.Mutex
+	binary string
+	passed map[string]bool
+	// directories caches the hashes of the files of each directory.
+	directories map[string]string
+}
+
+// loadValidationCache reads the cache file at path, if it was written by the
+// running test binary. The cache is disabled if path is empty or the binary
+// cannot be hashed.
+func loadValidationCache(path string) error {
+	validationCache.Lock()
+	defer validationCache.Unlock()
+	validationCache.passed = nil
+	validationCache.directories = map[string]string{}
+	if path == "" {
+		return nil
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	binary, err := hashFiles(executable)
+	if err != nil {
+		return err
+	}
+	validationCache.binary = binary
+	validationCache.passed = map[string]bool{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var cache validationCacheData
+	if err := json.Unmarshal(data, &cache); err != nil {
+		// Start over, the file is rewritten after the tests ran.
+		return nil
+	}
+	if cache.Binary == binary {
+		for _, key := range cache.Passed {
+			validationCache.passed[key] = true
+		}
+	}
+	return nil
+}
+
+// saveValidationCache writes the cache file at path.
+func saveValidationCache(path string) error {
+	validationCache.Lock()
+	defer validationCache.Unlock()
+	if validationCache.passed == nil {
+		return nil
+	}
+	cache := validationCacheData{Binary: validationCache.binary, Passed: []string{}}
+	for key := range validationCache.passed {
+		cache.Passed = append(cache.Passed, key)
+	}
+	sort.Strings(cache.Passed)
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// hashFiles returns the hash of the base names and contents of files. The
+// directory of the test binary changes with every build.
+func hashFiles(files ...string) (string, error) {
+	h := sha256.New()
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00", filepath.Base(file))
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// directoryHash returns the hash of the files of dir, not including its
+// subdirectories. The validation of a file depends on its neighbours, e.g. on
+// the CustomResourceDefinitions or LimitRanges of the directory.
+func directoryHash(dir string) (string, error) {
+	validationCache.Lock()
+	defer validationCache.Unlock()
+	if hash, found := validationCache.directories[dir]; found {
+		return hash, nil
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var files []string
+	for _, info := range infos {
+		if info.Mode().IsRegular() {
+			files = append(files, filepath.Join(dir, info.Name()))
+		}
+	}
+	hash, err := hashFiles(files...)
+	if err != nil {
+		return "", err
+	}
+	validationCache.directories[dir] = hash
+	return hash, nil
+}
+
+// resultFlags returns the values of the flags that may change the results
+// of the tests, e.g. -strict-decoding, in the order of their names. Flags of
+// the testing package and outputFlags are left out.
+func resultFlags() string {
+	var values []string
+	flag.VisitAll(func(f *flag.Flag) {
+		if !strings.HasPrefix(f.Name, "test.") && !outputFlags[f.Name] {
+			values = append(values, f.Name+"="+f.Value.String())
+		}
+	})
+	return strings.Join(values, "\x00")
+}
+
+// validationCacheKey returns the key of the file at path, validated with
+// example, in the cache: the hash of the files of its directory, of the pages
+// that state the stage of its feature gates, of its configuration and of the
+// resultFlags. It returns false if the cache is disabled or the key cannot be
+// computed.
+func validationCacheKey(path string, example exampleConfig) (string, bool) {
+	validationCache.Lock()
+	enabled := validationCache.passed != nil
+	validationCache.Unlock()
+	if !enabled {
+		return "", false
+	}
+	dir, err := directoryHash(filepath.Dir(path))
+	if err != nil {
+		return "", false
+	}
+	var pages []string
+	if len(example.FeatureGates) > 0 {
+		if pages, err = referencingPages(path); err != nil {
+			return "", false
+		}
+	}
+	hash, err := hashFiles(pages...)
+	if err != nil {
+		return "", false
+	}
+	config, err := json.Marshal(example)
+	if err != nil {
+		return "", false
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%s", path, dir, hash, config, resultFlags())
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// runCached runs fn, the tests of the file at path, unless the file passed
+// them without any finding in an earlier run and did not change since.
+func runCached(t *testing.T, path string, example exampleConfig, fn func()) {
+	key, cacheable := validationCacheKey(path, example)
+	if cacheable {
+		validationCache.Lock()
+		passed := validationCache.passed[key]
+		validationCache.Unlock()
+		if passed {
+			t.Skipf("%s did not change since it passed, see -validation-cache", path)
+		}
+	}
+	fn()
+	if cacheable && !t.Failed() && !hasFindings(t) {
+		validationCache.Lock()
+		validationCache.passed[key] = true
+		validationCache.Unlock()
+	}
+}
+
+// subtestName returns the name of the subtest of the file at path, its path
+// relative to the root of the website, e.g. docs/concepts/pod.yaml, which
+// can be selected with -run.
+func subtestName(path string) string {
+	return strings.TrimPrefix(filepath.ToSlash(filepath.Clean(path)), "../")
+}
+
+func TestValidationCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validation-cache")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	// The cache file is not in the directory of the example, whose files
+	// are part of the key.
+	if err := os.Mkdir(filepath.Join(dir, "examples"), 0755); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	path := filepath.Join(dir, "examples", "pod.yaml")
+	if err := ioutil.WriteFile(path, []byte("kind: Pod\n"), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cacheFile := filepath.Join(dir, "cache.json")
+	validationCache.Lock()
+	binary, passed, directories := validationCache.binary, validationCache.passed, validationCache.directories
+	validationCache.Unlock()
+	defer func() {
+		validationCache.Lock()
+		validationCache.binary, validationCache.passed, validationCache.directories = binary, passed, directories
+		validationCache.Unlock()
+	}()
+	if err := loadValidationCache(cacheFile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	runs := 0
+	t.Run("first", func(t *testing.T) {
+		runCached(t, path, exampleConfig{}, func() { runs++ })
+	})
+	if err := saveValidationCache(cacheFile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := loadValidationCache(cacheFile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Run("unchanged", func(t *testing.T) {
+		runCached(t, path, exampleConfig{}, func() { runs++ })
+	})
+	t.Run("other configuration", func(t *testing.T) {
+		runCached(t, path, exampleConfig{AllowPrivileged: true}, func() { runs++ })
+	})
+	flag.Set("strict-decoding", strconv.FormatBool(!*strictDecoding))
+	t.Run("other flags", func(t *testing.T) {
+		runCached(t, path, exampleConfig{}, func() { runs++ })
+	})
+	flag.Set("strict-decoding", strconv.FormatBool(!*strictDecoding))
+	if err := ioutil.WriteFile(path, []byte("kind: Service\n"), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := loadValidationCache(cacheFile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Run("changed", func(t *testing.T) {
+		runCached(t, path, exampleConfig{}, func() { runs++ })
+	})
+	if runs != 4 {
+		t.Errorf("Expected the unchanged file to be skipped, ran %d times", runs)
+	}
+
+	// A file with warnings is not cached, so that they are reported again.
+	runs = 0
+	for i := 0; i < 2; i++ {
+		t.Run("warnings", func(t *testing.T) {
+			runCached(t, path, exampleConfig{LenientDecoding: true}, func() {
+				runs++
+				reportWarning(t, finding{File: path}, "a warning")
+				// Not a warning about the documentation.
+				findings.Lock()
+				findings.list = findings.list[:len(findings.list)-1]
+				findings.Unlock()
+			})
+		})
+	}
+	if runs != 2 {
+		t.Errorf("Expected the file with warnings to run every time, ran %d times", runs)
+	}
+}
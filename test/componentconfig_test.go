@@ -0,0 +1,265 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package examples_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/apis/apiserver"
+	apiserverv1alpha1 "k8s.io/apiserver/pkg/apis/apiserver/v1alpha1"
+	"k8s.io/apiserver/pkg/apis/audit"
+	auditv1alpha1 "k8s.io/apiserver/pkg/apis/audit/v1alpha1"
+	auditv1beta1 "k8s.io/apiserver/pkg/apis/audit/v1beta1"
+	audit_validation "k8s.io/apiserver/pkg/apis/audit/validation"
+	"k8s.io/apiserver/pkg/server/options/encryptionconfig"
+	"k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+	kubeadmv1alpha1 "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm/v1alpha1"
+	"k8s.io/kubernetes/pkg/kubelet/apis/kubeletconfig"
+	kubeletconfigv1beta1 "k8s.io/kubernetes/pkg/kubelet/apis/kubeletconfig/v1beta1"
+	kubeletconfig_validation "k8s.io/kubernetes/pkg/kubelet/apis/kubeletconfig/validation"
+	"k8s.io/kubernetes/pkg/proxy/apis/kubeproxyconfig"
+	kubeproxyconfigv1alpha1 "k8s.io/kubernetes/pkg/proxy/apis/kubeproxyconfig/v1alpha1"
+	kubeproxyconfig_validation "k8s.io/kubernetes/pkg/proxy/apis/kubeproxyconfig/validation"
+	schedulerapi "k8s.io/kubernetes/pkg/scheduler/api"
+	schedulerapilatest "k8s.io/kubernetes/pkg/scheduler/api/latest"
+	scheduler_validation "k8s.io/kubernetes/pkg/scheduler/api/validation"
+	"k8s.io/kubernetes/plugin/pkg/admission/eventratelimit/apis/eventratelimit"
+	eventratelimitv1alpha1 "k8s.io/kubernetes/plugin/pkg/admission/eventratelimit/apis/eventratelimit/v1alpha1"
+	eventratelimit_validation "k8s.io/kubernetes/plugin/pkg/admission/eventratelimit/apis/eventratelimit/validation"
+)
+
+// componentConfig decodes and validates the configuration files of a
+// component, which have an apiVersion and a kind but are read from disk
+// rather than served by the API server.
+type componentConfig struct {
+	// Component reads the files, e.g. kube-scheduler.
+	Component string
+	// Validate decodes the JSON document data, applying the defaults of
+	// the component, and validates it. It returns an error if data does not
+	// decode.
+	Validate func(data []byte) (field.ErrorList, error)
+}
+
+// componentConfigs are the configuration files of all supported components,
+// see registerComponentConfig.
+var componentConfigs = map[schema.GroupKind]componentConfig{}
+
+// registerComponentConfig registers validate for the configuration files of
+// component with the group and kind gk, for all their versions.
+func registerComponentConfig(gk schema.GroupKind, component string, validate func(data []byte) (field.ErrorList, error)) {
+	if _, found := componentConfigs[gk]; found {
+		panic(fmt.Sprintf("component configuration %v registered twice", gk))
+	}
+	componentConfigs[gk] = componentConfig{component, validate}
+}
+
+// findComponentConfig returns the component configuration described by
+// typeMeta.
+func findComponentConfig(typeMeta metav1.TypeMeta) (componentConfig, bool) {
+	gvk := schema.FromAPIVersionAndKind(typeMeta.APIVersion, typeMeta.Kind)
+	config, found := componentConfigs[gvk.GroupKind()]
+	return config, found
+}
+
+// newComponentDecoder returns a decoder to the internal types of a scheme
+// built with addToScheme, which converts all the versions of the scheme and
+// applies their defaults.
+func newComponentDecoder(addToScheme ...func(*runtime.Scheme) error) runtime.Decoder {
+	scheme := runtime.NewScheme()
+	for _, add := range addToScheme {
+		if err := add(scheme); err != nil {
+			panic(err)
+		}
+	}
+	return serializer.NewCodecFactory(scheme).UniversalDecoder()
+}
+
+// aggregateErrors converts the errors of components whose validation does
+// not return field paths.
+func aggregateErrors(err error) field.ErrorList {
+	if err == nil {
+		return nil
+	}
+	errs := []error{err}
+	if agg, ok := err.(utilerrors.Aggregate); ok {
+		errs = agg.Errors()
+	}
+	var errors field.ErrorList
+	for _, err := range errs {
+		errors = append(errors, field.Invalid(nil, "", err.Error()))
+	}
+	return errors
+}
+
+// validateEncryptionConfig validates the providers of an encryption
+// configuration like the API server does when it reads the file, except that
+// KMS plugins are not contacted.
+func validateEncryptionConfig(config *encryptionconfig.EncryptionConfig) field.ErrorList {
+	var errors field.ErrorList
+	for i, resource := range config.Resources {
+		path := field.NewPath("resources").Index(i)
+		if len(resource.Resources) == 0 {
+			errors = append(errors, field.Required(path.Child("resources"), ""))
+		}
+		if len(resource.Providers) == 0 {
+			errors = append(errors, field.Required(path.Child("providers"), ""))
+		}
+		for j, provider := range resource.Providers {
+			providerPath := path.Child("providers").Index(j)
+			if kms := provider.KMS; kms != nil {
+				if kms.Name == "" {
+					errors = append(errors, field.Required(providerPath.Child("kms", "name"), ""))
+				}
+				if !strings.HasPrefix(kms.Endpoint, "unix://") {
+					errors = append(errors, field.Invalid(providerPath.Child("kms", "endpoint"), kms.Endpoint, "must be a unix:// socket"))
+				}
+				provider.KMS = nil
+				if provider != (encryptionconfig.ProviderConfig{}) {
+					errors = append(errors, field.Invalid(providerPath, "", "more than one provider specified in a single element"))
+				}
+				continue
+			}
+			single := &encryptionconfig.ResourceConfig{Providers: []encryptionconfig.ProviderConfig{provider}}
+			if _, err := encryptionconfig.GetPrefixTransformers(single); err != nil {
+				errors = append(errors, field.Invalid(providerPath, "", err.Error()))
+			}
+		}
+	}
+	return errors
+}
+
+func init() {
+	auditDecoder := newComponentDecoder(audit.AddToScheme, auditv1alpha1.AddToScheme, auditv1beta1.AddToScheme)
+	registerComponentConfig(audit.Kind("Policy"), "kube-apiserver", func(data []byte) (field.ErrorList, error) {
+		policy := &audit.Policy{}
+		if err := runtime.DecodeInto(auditDecoder, data, policy); err != nil {
+			return nil, err
+		}
+		errors := audit_validation.ValidatePolicy(policy)
+		if len(policy.Rules) == 0 {
+			errors = append(errors, field.Required(field.NewPath("rules"), "the API server refuses a policy without rules"))
+		}
+		return errors, nil
+	})
+	admissionDecoder := newComponentDecoder(apiserver.AddToScheme, apiserverv1alpha1.AddToScheme)
+	registerComponentConfig(apiserver.Kind("AdmissionConfiguration"), "kube-apiserver", func(data []byte) (field.ErrorList, error) {
+		// The configuration of each plugin is decoded by the plugin.
+		return nil, runtime.DecodeInto(admissionDecoder, data, &apiserver.AdmissionConfiguration{})
+	})
+	registerComponentConfig(schema.GroupKind{Kind: "EncryptionConfig"}, "kube-apiserver", func(data []byte) (field.ErrorList, error) {
+		// Encryption configurations are not versioned like API objects.
+		config := &encryptionconfig.EncryptionConfig{}
+		if err := json.Unmarshal(data, config); err != nil {
+			return nil, err
+		}
+		return validateEncryptionConfig(config), nil
+	})
+	eventRateLimitDecoder := newComponentDecoder(eventratelimit.AddToScheme, eventratelimitv1alpha1.AddToScheme)
+	registerComponentConfig(eventratelimit.Kind("Configuration"), "kube-apiserver", func(data []byte) (field.ErrorList, error) {
+		config := &eventratelimit.Configuration{}
+		if err := runtime.DecodeInto(eventRateLimitDecoder, data, config); err != nil {
+			return nil, err
+		}
+		return eventratelimit_validation.ValidateConfiguration(config), nil
+	})
+	registerComponentConfig(schema.GroupKind{Kind: "Policy"}, "kube-scheduler", func(data []byte) (field.ErrorList, error) {
+		policy := &schedulerapi.Policy{}
+		if err := runtime.DecodeInto(schedulerapilatest.Codec, data, policy); err != nil {
+			return nil, err
+		}
+		return aggregateErrors(scheduler_validation.ValidatePolicy(*policy)), nil
+	})
+	kubeletDecoder := newComponentDecoder(kubeletconfig.AddToScheme, kubeletconfigv1beta1.AddToScheme)
+	registerComponentConfig(kubeletconfig.SchemeGroupVersion.WithKind("KubeletConfiguration").GroupKind(), "kubelet", func(data []byte) (field.ErrorList, error) {
+		config := &kubeletconfig.KubeletConfiguration{}
+		if err := runtime.DecodeInto(kubeletDecoder, data, config); err != nil {
+			return nil, err
+		}
+		return aggregateErrors(kubeletconfig_validation.ValidateKubeletConfiguration(config)), nil
+	})
+	kubeProxyDecoder := newComponentDecoder(kubeproxyconfig.AddToScheme, kubeproxyconfigv1alpha1.AddToScheme)
+	registerComponentConfig(kubeproxyconfig.Kind("KubeProxyConfiguration"), "kube-proxy", func(data []byte) (field.ErrorList, error) {
+		config := &kubeproxyconfig.KubeProxyConfiguration{}
+		if err := runtime.DecodeInto(kubeProxyDecoder, data, config); err != nil {
+			return nil, err
+		}
+		return kubeproxyconfig_validation.Validate(config), nil
+	})
+	// The validation of kubeadm depends on the cloud providers, so its
+	// configurations are only decoded.
+	kubeadmDecoder := newComponentDecoder(kubeadm.AddToScheme, kubeadmv1alpha1.AddToScheme)
+	registerComponentConfig(kubeadm.Kind("MasterConfiguration"), "kubeadm", func(data []byte) (field.ErrorList, error) {
+		return nil, runtime.DecodeInto(kubeadmDecoder, data, &kubeadm.MasterConfiguration{})
+	})
+	registerComponentConfig(kubeadm.Kind("NodeConfiguration"), "kubeadm", func(data []byte) (field.ErrorList, error) {
+		return nil, runtime.DecodeInto(kubeadmDecoder, data, &kubeadm.NodeConfiguration{})
+	})
+}
+
+func TestComponentConfigs(t *testing.T) {
+	cases := []struct {
+		data     string
+		expected []string
+	}{
+		{`{"apiVersion": "audit.k8s.io/v1beta1", "kind": "Policy", "rules": [{"level": "Metadata"}]}`, nil},
+		{`{"apiVersion": "audit.k8s.io/v1beta1", "kind": "Policy", "rules": [{"level": "Everything"}]}`, []string{"rules[0].level"}},
+		{`{"apiVersion": "v1", "kind": "Policy", "priorities": [{"name": "LeastRequestedPriority", "weight": 1}]}`, nil},
+		{`{"apiVersion": "v1", "kind": "Policy", "priorities": [{"name": "LeastRequestedPriority", "weight": 0}]}`, []string{""}},
+		{`{"apiVersion": "v1", "kind": "EncryptionConfig", "resources": [{"resources": ["secrets"], "providers": [
+			{"aescbc": {"keys": [{"name": "key1", "secret": "c2VjcmV0"}]}},
+			{"kms": {"name": "myKmsPlugin", "endpoint": "/tmp/socketfile.sock"}},
+			{"identity": {}}]}]}`, []string{"resources[0].providers[0]", "resources[0].providers[1].kms.endpoint"}},
+		{`{"apiVersion": "kubelet.config.k8s.io/v1beta1", "kind": "KubeletConfiguration", "evictionHard": {"memory.available": "200Mi"}}`, nil},
+	}
+	for _, c := range cases {
+		typeMeta, err := documentTypeMeta([]byte(c.data))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		config, found := findComponentConfig(typeMeta)
+		if !found {
+			t.Fatalf("Expected a component configuration for %v", typeMeta)
+		}
+		errors, err := config.Validate([]byte(c.data))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.data, err)
+			continue
+		}
+		var actual []string
+		for _, err := range errors {
+			actual = append(actual, err.Field)
+		}
+		sort.Strings(actual)
+		if strings.Join(actual, ",") != strings.Join(c.expected, ",") {
+			t.Errorf("%s: expected errors for %v, got %v", c.data, c.expected, errors)
+		}
+	}
+
+	if _, found := findComponentConfig(metav1.TypeMeta{APIVersion: "abac.authorization.kubernetes.io/v1beta1", Kind: "Policy"}); found {
+		t.Errorf("Expected no component configuration for ABAC policies")
+	}
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package examples_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	openapierrors "github.com/go-openapi/errors"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	crvalidation "k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
+	apimachineryvalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// customResourceDefinitions indexes CustomResourceDefinitions by the group
+// and kind of their custom resources.
+type customResourceDefinitions map[schema.GroupKind]*apiextensions.CustomResourceDefinition
+
+// addDocuments adds the CustomResourceDefinitions among the JSON documents
+// in docs, replacing earlier definitions of the same kinds.
+func (c customResourceDefinitions) addDocuments(docs [][]byte) {
+	for _, data := range docs {
+		typeMeta, err := documentTypeMeta(data)
+		if err != nil || typeMeta.Kind != "CustomResourceDefinition" {
+			continue
+		}
+		obj, err := decodeDocument(data)
+		if err != nil {
+			continue
+		}
+		if crd, ok := obj.(*apiextensions.CustomResourceDefinition); ok {
+			c[schema.GroupKind{Group: crd.Spec.Group, Kind: crd.Spec.Names.Kind}] = crd
+		}
+	}
+}
+
+// lookup returns the definition of the custom resource described by
+// typeMeta.
+func (c customResourceDefinitions) lookup(typeMeta metav1.TypeMeta) (*apiextensions.CustomResourceDefinition, bool) {
+	gvk := schema.FromAPIVersionAndKind(typeMeta.APIVersion, typeMeta.Kind)
+	crd, found := c[gvk.GroupKind()]
+	return crd, found
+}
+
+// directoryCRDs caches the CustomResourceDefinitions of each directory.
+var directoryCRDs struct {
+	sync.Mutex
+	cache map[string]customResourceDefinitions
+}
+
+// directoryCustomResourceDefinitions returns the CustomResourceDefinitions
+// declared in the example files and in the code blocks of the Markdown pages
+// of dir, not including its subdirectories. The result must not be modified.
+func directoryCustomResourceDefinitions(dir string) customResourceDefinitions {
+	directoryCRDs.Lock()
+	defer directoryCRDs.Unlock()
+	if crds, found := directoryCRDs.cache[dir]; found {
+		return crds
+	}
+	crds := customResourceDefinitions{}
+	if directoryCRDs.cache == nil {
+		directoryCRDs.cache = map[string]customResourceDefinitions{}
+	}
+	directoryCRDs.cache[dir] = crds
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return crds
+	}
+	for _, info := range files {
+		if info.IsDir() {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, info.Name()))
+		if err != nil {
+			continue
+		}
+		switch filepath.Ext(info.Name()) {
+		case ".yaml", ".json":
+			if docs, err := splitDocuments(data); err == nil {
+				crds.addDocuments(docs)
+			}
+		case ".md":
+			for _, block := range extractCodeBlocks(data) {
+				if docs, err := splitDocuments([]byte(block.Content)); err == nil {
+					crds.addDocuments(docs)
+				}
+			}
+		}
+	}
+	return crds
+}
+
+// validateCustomResource validates the custom resource in the JSON document
+// data against its definition crd: its apiVersion, its metadata and the
+// openAPIV3Schema of the definition, if any.
+func validateCustomResource(crd *apiextensions.CustomResourceDefinition, data []byte) field.ErrorList {
+	u := &unstructured.Unstructured{}
+	if err := json.Unmarshal(data, &u.Object); err != nil {
+		return field.ErrorList{field.Invalid(field.NewPath(""), string(data), err.Error())}
+	}
+	var errors field.ErrorList
+	if gv := u.GroupVersionKind().GroupVersion(); gv.Version != crd.Spec.Version {
+		errors = append(errors, field.NotSupported(field.NewPath("apiVersion"), gv.String(), []string{crd.Spec.Group + "/" + crd.Spec.Version}))
+	}
+	namespaced := crd.Spec.Scope == apiextensions.NamespaceScoped
+	if namespaced && u.GetNamespace() == "" {
+		u.SetNamespace(metav1.NamespaceDefault)
+	}
+	errors = append(errors, apimachineryvalidation.ValidateObjectMetaAccessor(u, namespaced, apimachineryvalidation.NameIsDNSSubdomain, field.NewPath("metadata"))...)
+	if crd.Spec.Validation == nil {
+		return errors
+	}
+	validator, _, err := crvalidation.NewSchemaValidator(crd.Spec.Validation)
+	if err != nil {
+		return append(errors, field.InternalError(field.NewPath(""), fmt.Errorf("invalid openAPIV3Schema in %s: %v", crd.Name, err)))
+	}
+	for _, err := range validator.Validate(u.UnstructuredContent()).Errors {
+		path := ""
+		var value interface{}
+		if v, ok := err.(*openapierrors.Validation); ok {
+			path, value = v.Name, v.Value
+		}
+		errors = append(errors, &field.Error{Type: field.ErrorTypeInvalid, Field: path, BadValue: value, Detail: err.Error()})
+	}
+	return errors
+}
+
+func TestValidateCustomResource(t *testing.T) {
+	crds := customResourceDefinitions{}
+	crds.addDocuments([][]byte{[]byte(`{
+  "apiVersion": "apiextensions.k8s.io/v1beta1",
+  "kind": "CustomResourceDefinition",
+  "metadata": {"name": "crontabs.stable.example.com"},
+  "spec": {
+    "group": "stable.example.com",
+    "version": "v1",
+    "scope": "Namespaced",
+    "names": {"plural": "crontabs", "singular": "crontab", "kind": "CronTab"},
+    "validation": {"openAPIV3Schema": {"properties": {"spec": {"properties": {
+      "cronSpec": {"type": "string", "pattern": "^(\\d+|\\*)(/\\d+)?(\\s+(\\d+|\\*)(/\\d+)?){4}$"},
+      "replicas": {"type": "integer", "minimum": 1, "maximum": 10}
+    }}}}}
+  }
+}`)})
+	crontab := func(apiVersion, name, cronSpec string, replicas int) []byte {
+		return []byte(fmt.Sprintf(`{"apiVersion": %q, "kind": "CronTab", "metadata": {"name": %q}, "spec": {"cronSpec": %q, "replicas": %d}}`, apiVersion, name, cronSpec, replicas))
+	}
+	cases := []struct {
+		data     []byte
+		expected []string
+	}{
+		{crontab("stable.example.com/v1", "my-new-cron-object", "* * * * */5", 5), nil},
+		{crontab("stable.example.com/v1", "my-new-cron-object", "* * * *", 15), []string{"spec.cronSpec", "spec.replicas"}},
+		{crontab("stable.example.com/v2", "my-new-cron-object", "* * * * */5", 5), []string{"apiVersion"}},
+		{crontab("stable.example.com/v1", "", "* * * * */5", 5), []string{"metadata.name"}},
+	}
+	for _, c := range cases {
+		typeMeta, err := documentTypeMeta(c.data)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		crd, found := crds.lookup(typeMeta)
+		if !found {
+			t.Fatalf("Expected a definition for %v", typeMeta)
+		}
+		var actual []string
+		for _, err := range validateCustomResource(crd, c.data) {
+			actual = append(actual, err.Field)
+		}
+		sort.Strings(actual)
+		if strings.Join(actual, ",") != strings.Join(c.expected, ",") {
+			t.Errorf("%s: expected errors for %v, got %v", c.data, c.expected, actual)
+		}
+	}
+}
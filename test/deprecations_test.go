@@ -0,0 +1,139 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package examples_test
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	examples "k8s.io/website/test"
+)
+
+var deprecatedAPIsMode = flag.String("deprecated-apis", "report", `How to handle examples that use deprecated or removed API versions: "report" only logs them, "fail" fails the test.`)
+
+// checkDeprecation returns a description of the problem if apiVersion and kind
+// are deprecated or removed as of version latest, or an empty string.
+func checkDeprecation(apiVersion, kind string, latest minorVersion) (string, error) {
+	api, found := examples.FindDeprecatedAPI(apiVersion, kind)
+	if !found {
+		return "", nil
+	}
+	replacementKind := api.ReplacementKind
+	if replacementKind == "" {
+		replacementKind = kind
+	}
+	if api.RemovedIn != "" {
+		removedIn, err := parseMinorVersion(api.RemovedIn)
+		if err != nil {
+			return "", err
+		}
+		if !latest.Less(removedIn) {
+			return fmt.Sprintf("%s %s was removed in %s, use %s %s instead", apiVersion, kind, removedIn, api.Replacement, replacementKind), nil
+		}
+	}
+	deprecatedIn, err := parseMinorVersion(api.DeprecatedIn)
+	if err != nil {
+		return "", err
+	}
+	if !latest.Less(deprecatedIn) {
+		return fmt.Sprintf("%s %s is deprecated since %s, use %s %s instead", apiVersion, kind, deprecatedIn, api.Replacement, replacementKind), nil
+	}
+	return "", nil
+}
+
+// TestDeprecatedAPIs reports example files and Markdown code blocks that use
+// an API version that is deprecated or removed as of the latest version of
+// the website. By default the findings are only logged; run with
+// -deprecated-apis=fail to fail the test instead.
+func TestDeprecatedAPIs(t *testing.T) {
+	var report func(t *testing.T, f finding, format string, args ...interface{})
+	switch *deprecatedAPIsMode {
+	case "report":
+		report = reportWarning
+	case "fail":
+		report = reportError
+	default:
+		t.Fatalf("Invalid value %q for -deprecated-apis, expected \"report\" or \"fail\"", *deprecatedAPIsMode)
+	}
+	latest, err := siteLatestVersion()
+	if err != nil {
+		t.Fatalf("Unable to determine the latest version: %v", err)
+	}
+
+	check := func(f finding, data []byte) {
+		typeMeta, err := documentTypeMeta(data)
+		if err != nil || typeMeta.APIVersion == "" || typeMeta.Kind == "" {
+			return
+		}
+		problem, err := checkDeprecation(typeMeta.APIVersion, typeMeta.Kind, latest)
+		if err != nil {
+			t.Errorf("Invalid entry in examples.DeprecatedAPIs for %s %s: %v", typeMeta.APIVersion, typeMeta.Kind, err)
+			return
+		}
+		if problem != "" {
+			f.Kind = typeMeta.Kind
+			f.Field = "apiVersion"
+			report(t, f, "%s", problem)
+		}
+	}
+
+	err = walkConfigFiles(examplesRoot, func(name, path string, docs [][]byte, config *directoryConfig) {
+		for i, data := range docs {
+			check(finding{File: path, Document: i}, data)
+		}
+	})
+	if err != nil {
+		t.Errorf("Expected no error, Got %v on Path %v", err, examplesRoot)
+	}
+
+	for _, root := range markdownRoots {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || filepath.Ext(path) != ".md" {
+				return nil
+			}
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				reportError(t, finding{File: path}, "unable to read file: %v", err)
+				return nil
+			}
+			for _, block := range extractCodeBlocks(data) {
+				if block.Lang != "yaml" && block.Lang != "yml" && block.Lang != "json" {
+					continue
+				}
+				docs, err := splitDocuments([]byte(block.Content))
+				if err != nil {
+					// Reported by TestReadme unless the block is skipped.
+					continue
+				}
+				for i, data := range docs {
+					check(finding{File: path, Document: i, Line: block.Line}, data)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Errorf("Expected no error, Got %v on Path %v", err, root)
+		}
+	}
+}
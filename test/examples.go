@@ -14,4 +14,51 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
+// Package examples holds data shared by the tests of the examples in the
+// documentation and the tools that maintain them.
 package examples
+
+// DeprecatedAPI describes an API version that examples should no longer use.
+type DeprecatedAPI struct {
+	GroupVersion string
+	// Kind is empty if every kind of GroupVersion is deprecated.
+	Kind         string
+	DeprecatedIn string
+	// RemovedIn is empty if the API version is still served.
+	RemovedIn string
+	// Replacement is the group/version to use instead.
+	Replacement string
+	// ReplacementKind is set if the kind changes as well.
+	ReplacementKind string
+}
+
+// DeprecatedAPIs are the API versions that are deprecated or removed, with
+// their replacements. Please keep the list sorted by group version and kind.
+var DeprecatedAPIs = []DeprecatedAPI{
+	{GroupVersion: "admissionregistration.k8s.io/v1alpha1", Kind: "ExternalAdmissionHookConfiguration", DeprecatedIn: "v1.9", RemovedIn: "v1.9", Replacement: "admissionregistration.k8s.io/v1beta1", ReplacementKind: "ValidatingWebhookConfiguration"},
+	{GroupVersion: "apps/v1beta1", DeprecatedIn: "v1.9", RemovedIn: "v1.16", Replacement: "apps/v1"},
+	{GroupVersion: "apps/v1beta2", DeprecatedIn: "v1.9", RemovedIn: "v1.16", Replacement: "apps/v1"},
+	{GroupVersion: "autoscaling/v2alpha1", Kind: "HorizontalPodAutoscaler", DeprecatedIn: "v1.8", Replacement: "autoscaling/v2beta1"},
+	{GroupVersion: "batch/v2alpha1", Kind: "CronJob", DeprecatedIn: "v1.8", Replacement: "batch/v1beta1"},
+	{GroupVersion: "extensions/v1beta1", Kind: "DaemonSet", DeprecatedIn: "v1.9", RemovedIn: "v1.16", Replacement: "apps/v1"},
+	{GroupVersion: "extensions/v1beta1", Kind: "Deployment", DeprecatedIn: "v1.9", RemovedIn: "v1.16", Replacement: "apps/v1"},
+	{GroupVersion: "extensions/v1beta1", Kind: "NetworkPolicy", DeprecatedIn: "v1.9", RemovedIn: "v1.16", Replacement: "networking.k8s.io/v1"},
+	{GroupVersion: "extensions/v1beta1", Kind: "PodSecurityPolicy", DeprecatedIn: "v1.10", RemovedIn: "v1.16", Replacement: "policy/v1beta1"},
+	{GroupVersion: "extensions/v1beta1", Kind: "ReplicaSet", DeprecatedIn: "v1.9", RemovedIn: "v1.16", Replacement: "apps/v1"},
+	{GroupVersion: "extensions/v1beta1", Kind: "ThirdPartyResource", DeprecatedIn: "v1.7", RemovedIn: "v1.8", Replacement: "apiextensions.k8s.io/v1beta1", ReplacementKind: "CustomResourceDefinition"},
+	{GroupVersion: "policy/v1alpha1", Kind: "PodDisruptionBudget", DeprecatedIn: "v1.5", RemovedIn: "v1.5", Replacement: "policy/v1beta1"},
+	{GroupVersion: "rbac.authorization.k8s.io/v1alpha1", DeprecatedIn: "v1.8", Replacement: "rbac.authorization.k8s.io/v1"},
+	{GroupVersion: "rbac.authorization.k8s.io/v1beta1", DeprecatedIn: "v1.8", Replacement: "rbac.authorization.k8s.io/v1"},
+	{GroupVersion: "storage.k8s.io/v1beta1", Kind: "StorageClass", DeprecatedIn: "v1.6", Replacement: "storage.k8s.io/v1"},
+}
+
+// FindDeprecatedAPI returns the entry of DeprecatedAPIs that matches
+// apiVersion and kind.
+func FindDeprecatedAPI(apiVersion, kind string) (DeprecatedAPI, bool) {
+	for _, api := range DeprecatedAPIs {
+		if api.GroupVersion == apiVersion && (api.Kind == "" || api.Kind == kind) {
+			return api, true
+		}
+	}
+	return DeprecatedAPI{}, false
+}
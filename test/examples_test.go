@@ -19,208 +19,230 @@ package examples_test
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"strings"
 	"testing"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/apimachinery/pkg/util/yaml"
-	utilfeature "k8s.io/apiserver/pkg/util/feature"
-	"k8s.io/kubernetes/pkg/api/testapi"
-	"k8s.io/kubernetes/pkg/apis/admissionregistration"
-	ar_validation "k8s.io/kubernetes/pkg/apis/admissionregistration/validation"
-	"k8s.io/kubernetes/pkg/apis/apps"
-	apps_validation "k8s.io/kubernetes/pkg/apis/apps/validation"
-	"k8s.io/kubernetes/pkg/apis/autoscaling"
-	autoscaling_validation "k8s.io/kubernetes/pkg/apis/autoscaling/validation"
-	"k8s.io/kubernetes/pkg/apis/batch"
-	batch_validation "k8s.io/kubernetes/pkg/apis/batch/validation"
-	api "k8s.io/kubernetes/pkg/apis/core"
-	"k8s.io/kubernetes/pkg/apis/core/validation"
-	"k8s.io/kubernetes/pkg/apis/extensions"
-	ext_validation "k8s.io/kubernetes/pkg/apis/extensions/validation"
-	"k8s.io/kubernetes/pkg/apis/policy"
-	policy_validation "k8s.io/kubernetes/pkg/apis/policy/validation"
-	"k8s.io/kubernetes/pkg/apis/rbac"
-	rbac_validation "k8s.io/kubernetes/pkg/apis/rbac/validation"
-	"k8s.io/kubernetes/pkg/apis/settings"
-	settings_validation "k8s.io/kubernetes/pkg/apis/settings/validation"
-	"k8s.io/kubernetes/pkg/apis/storage"
-	storage_validation "k8s.io/kubernetes/pkg/apis/storage/validation"
-	"k8s.io/kubernetes/pkg/capabilities"
-	"k8s.io/kubernetes/pkg/registry/batch/job"
-	schedulerapilatest "k8s.io/kubernetes/pkg/scheduler/api/latest"
+	"k8s.io/kubernetes/pkg/api/legacyscheme"
+	// testapi installs all API groups into legacyscheme.Scheme.
+	_ "k8s.io/kubernetes/pkg/api/testapi"
 )
 
-func validateObject(obj runtime.Object) (errors field.ErrorList) {
-	// Enable CustomPodDNS for testing
-	utilfeature.DefaultFeatureGate.Set("CustomPodDNS=true")
-	switch t := obj.(type) {
-	case *admissionregistration.InitializerConfiguration:
-		// cluster scope resource
-		errors = ar_validation.ValidateInitializerConfiguration(t)
-	case *api.ConfigMap:
-		if t.Namespace == "" {
-			t.Namespace = api.NamespaceDefault
-		}
-		errors = validation.ValidateConfigMap(t)
-	case *api.Endpoints:
-		if t.Namespace == "" {
-			t.Namespace = api.NamespaceDefault
-		}
-		errors = validation.ValidateEndpoints(t)
-	case *api.LimitRange:
-		if t.Namespace == "" {
-			t.Namespace = api.NamespaceDefault
-		}
-		errors = validation.ValidateLimitRange(t)
-	case *api.Namespace:
-		errors = validation.ValidateNamespace(t)
-	case *api.PersistentVolume:
-		errors = validation.ValidatePersistentVolume(t)
-	case *api.PersistentVolumeClaim:
-		if t.Namespace == "" {
-			t.Namespace = api.NamespaceDefault
-		}
-		errors = validation.ValidatePersistentVolumeClaim(t)
-	case *api.Pod:
-		if t.Namespace == "" {
-			t.Namespace = api.NamespaceDefault
-		}
-		errors = validation.ValidatePod(t)
-	case *api.PodList:
-		for i := range t.Items {
-			errors = append(errors, validateObject(&t.Items[i])...)
-		}
-	case *api.PodTemplate:
-		if t.Namespace == "" {
-			t.Namespace = api.NamespaceDefault
-		}
-		errors = validation.ValidatePodTemplate(t)
-	case *api.ReplicationController:
-		if t.Namespace == "" {
-			t.Namespace = api.NamespaceDefault
-		}
-		errors = validation.ValidateReplicationController(t)
-	case *api.ReplicationControllerList:
-		for i := range t.Items {
-			errors = append(errors, validateObject(&t.Items[i])...)
-		}
-	case *api.ResourceQuota:
-		if t.Namespace == "" {
-			t.Namespace = api.NamespaceDefault
-		}
-		errors = validation.ValidateResourceQuota(t)
-	case *api.Secret:
-		if t.Namespace == "" {
-			t.Namespace = api.NamespaceDefault
-		}
-		errors = validation.ValidateSecret(t)
-	case *api.Service:
-		if t.Namespace == "" {
-			t.Namespace = api.NamespaceDefault
-		}
-		errors = validation.ValidateService(t)
-	case *api.ServiceAccount:
-		if t.Namespace == "" {
-			t.Namespace = api.NamespaceDefault
-		}
-		errors = validation.ValidateServiceAccount(t)
-	case *api.ServiceList:
-		for i := range t.Items {
-			errors = append(errors, validateObject(&t.Items[i])...)
-		}
-	case *apps.StatefulSet:
-		if t.Namespace == "" {
-			t.Namespace = api.NamespaceDefault
-		}
-		errors = apps_validation.ValidateStatefulSet(t)
-	case *autoscaling.HorizontalPodAutoscaler:
-		if t.Namespace == "" {
-			t.Namespace = api.NamespaceDefault
-		}
-		errors = autoscaling_validation.ValidateHorizontalPodAutoscaler(t)
-	case *batch.Job:
-		if t.Namespace == "" {
-			t.Namespace = api.NamespaceDefault
+// examplesRoot is the directory that is searched recursively for example
+// manifests.
+const examplesRoot = "../docs"
+
+// directoryConfigFile is the optional file in a directory of examples that
+// describes them. Jekyll does not publish files starting with a dot.
+const directoryConfigFile = ".examples.yaml"
+
+// directoryConfig is the content of directoryConfigFile.
+type directoryConfig struct {
+	// Ignore lists files and subdirectories of the directory that do not
+	// contain Kubernetes API objects and must not be decoded.
+	Ignore []string `json:"ignore"`
+	// Files describes individual files of the directory.
+	Files map[string]exampleConfig `json:"files"`
+	// External lists the objects, as Kind/name, that the examples of the
+	// directory refer to and that neither they nor the pages create, e.g.
+	// Secret/federation-controller-manager-kubeconfig. See
+	// TestExampleUpdates.
+	External []string `json:"external"`
+	// FeatureGates are enabled while validating the examples of the
+	// directory and of its subdirectories, e.g. PodShareProcessNamespace=true.
+	FeatureGates []string `json:"featureGates"`
+	// Lint lists the lint rules run on the examples of the directory and of
+	// its subdirectories, see lintRules. Defaults to the rules of the parent
+	// directory, or to all rules; an empty list disables linting.
+	Lint []string `json:"lint"`
+	// MinVersion is the oldest Kubernetes release, e.g. v1.9, that the
+	// examples of the directory and of its subdirectories must be valid
+	// for. Defaults to the one of the parent directory. See
+	// TestExampleSchemaVersions.
+	MinVersion string `json:"minVersion"`
+}
+
+// exampleConfig describes one example file in directoryConfigFile.
+type exampleConfig struct {
+	// Kinds are the kinds of the documents of the file, in order. If empty,
+	// the file may contain any kind.
+	Kinds []string `json:"kinds"`
+	// FeatureGates are enabled while validating the example, in addition to
+	// the ones of its directory, e.g. PodShareProcessNamespace=true.
+	FeatureGates []string `json:"featureGates"`
+	// AllowPrivileged allows privileged containers in the example.
+	AllowPrivileged bool `json:"allowPrivileged"`
+	// SuppressLint lists the lint rules that are not run on the example.
+	SuppressLint []string `json:"suppressLint"`
+	// LenientDecoding allows unknown and duplicate keys in the example,
+	// e.g. to show that a misspelled field is ignored.
+	LenientDecoding bool `json:"lenientDecoding"`
+	// ExpectInvalid declares that the API server rejects the example, which
+	// a page shows to explain the error.
+	ExpectInvalid *expectedRejection `json:"expectInvalid"`
+	// MinVersion is the oldest Kubernetes release that the example must be
+	// valid for. Defaults to the one of the directory.
+	MinVersion string `json:"minVersion"`
+}
+
+// loadDirectoryConfig reads directoryConfigFile in dir and adds the feature
+// gates, lint rules and minimum version of parent, the configuration of the parent directory,
+// if any. A missing file results in an empty configuration.
+func loadDirectoryConfig(dir string, parent *directoryConfig) (*directoryConfig, error) {
+	config := &directoryConfig{}
+	path := filepath.Join(dir, directoryConfigFile)
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		out, err := yaml.ToJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
 		}
-		// Job needs generateSelector called before validation, and job.Validate does this.
-		// See: https://github.com/kubernetes/kubernetes/issues/20951#issuecomment-187787040
-		t.ObjectMeta.UID = types.UID("fakeuid")
-		if strings.Index(t.ObjectMeta.Name, "$") > -1 {
-			t.ObjectMeta.Name = "skip-for-good"
+		if err := json.Unmarshal(out, config); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
 		}
-		errors = job.Strategy.Validate(nil, t)
-	case *extensions.DaemonSet:
-		if t.Namespace == "" {
-			t.Namespace = api.NamespaceDefault
+	}
+	if parent != nil {
+		config.FeatureGates = append(append([]string{}, parent.FeatureGates...), config.FeatureGates...)
+		if config.Lint == nil {
+			config.Lint = parent.Lint
 		}
-		errors = ext_validation.ValidateDaemonSet(t)
-	case *extensions.Deployment:
-		if t.Namespace == "" {
-			t.Namespace = api.NamespaceDefault
+		if config.MinVersion == "" {
+			config.MinVersion = parent.MinVersion
 		}
-		errors = ext_validation.ValidateDeployment(t)
-	case *extensions.Ingress:
-		if t.Namespace == "" {
-			t.Namespace = api.NamespaceDefault
+	}
+	return config, nil
+}
+
+// ignored returns true if name, a file or subdirectory of the directory, is
+// listed in c.Ignore.
+func (c *directoryConfig) ignored(name string) bool {
+	for _, ignore := range c.Ignore {
+		if strings.TrimSuffix(ignore, "/") == name {
+			return true
 		}
-		errors = ext_validation.ValidateIngress(t)
-	case *extensions.PodSecurityPolicy:
-		errors = ext_validation.ValidatePodSecurityPolicy(t)
-	case *extensions.ReplicaSet:
-		if t.Namespace == "" {
-			t.Namespace = api.NamespaceDefault
+	}
+	return false
+}
+
+// example returns the configuration of the file name of the directory, with
+// the feature gates and minimum version of the directory.
+func (c *directoryConfig) example(name string) exampleConfig {
+	example := c.Files[name]
+	example.FeatureGates = append(append([]string{}, c.FeatureGates...), example.FeatureGates...)
+	if example.MinVersion == "" {
+		example.MinVersion = c.MinVersion
+	}
+	return example
+}
+
+// documentTypeMeta returns the apiVersion and kind of the JSON document in
+// data.
+func documentTypeMeta(data []byte) (metav1.TypeMeta, error) {
+	var typeMeta metav1.TypeMeta
+	err := json.Unmarshal(data, &typeMeta)
+	return typeMeta, err
+}
+
+// newObjectForDocument returns an empty internal object of the type named by
+// the apiVersion and kind of the JSON document in data.
+func newObjectForDocument(data []byte) (runtime.Object, error) {
+	typeMeta, err := documentTypeMeta(data)
+	if err != nil {
+		return nil, err
+	}
+	if typeMeta.APIVersion == "" || typeMeta.Kind == "" {
+		return nil, fmt.Errorf("document has no apiVersion or kind, add the file to the ignore list of %s if it is not a Kubernetes object", directoryConfigFile)
+	}
+	gvk := schema.FromAPIVersionAndKind(typeMeta.APIVersion, typeMeta.Kind)
+	if !legacyscheme.Scheme.Recognizes(gvk) {
+		return nil, fmt.Errorf("unknown apiVersion %q and kind %q", typeMeta.APIVersion, typeMeta.Kind)
+	}
+	gvk.Version = runtime.APIVersionInternal
+	return legacyscheme.Scheme.New(gvk)
+}
+
+// decodeDocument decodes the JSON document in data into an internal object
+// of the type inferred from its apiVersion and kind.
+func decodeDocument(data []byte) (runtime.Object, error) {
+	obj, err := newObjectForDocument(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := runtime.DecodeInto(legacyscheme.Codecs.UniversalDecoder(), data, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// splitDocuments splits YAML data into its documents and converts each of
+// them to JSON. Empty documents (e.g. pure comments) are dropped.
+func splitDocuments(data []byte) ([][]byte, error) {
+	var docs [][]byte
+	splitter := yaml.NewYAMLReader(bufio.NewReader(bytes.NewBuffer(data)))
+	for {
+		doc, err := splitter.Read()
+		if err == io.EOF {
+			break
 		}
-		errors = ext_validation.ValidateReplicaSet(t)
-	case *batch.CronJob:
-		if t.Namespace == "" {
-			t.Namespace = api.NamespaceDefault
+		if err != nil {
+			return nil, err
 		}
-		errors = batch_validation.ValidateCronJob(t)
-	case *policy.PodDisruptionBudget:
-		if t.Namespace == "" {
-			t.Namespace = api.NamespaceDefault
+		out, err := yaml.ToJSON(doc)
+		if err != nil {
+			return nil, err
 		}
-		errors = policy_validation.ValidatePodDisruptionBudget(t)
-	case *rbac.ClusterRoleBinding:
-		// clusterolebinding does not accept namespace
-		errors = rbac_validation.ValidateClusterRoleBinding(t)
-	case *settings.PodPreset:
-		if t.Namespace == "" {
-			t.Namespace = api.NamespaceDefault
+		// deal with "empty" document (e.g. pure comments)
+		if string(out) != "null" {
+			docs = append(docs, out)
 		}
-		errors = settings_validation.ValidatePodPreset(t)
-	case *storage.StorageClass:
-		// storageclass does not accept namespace
-		errors = storage_validation.ValidateStorageClass(t)
-	default:
-		errors = field.ErrorList{}
-		errors = append(errors, field.InternalError(field.NewPath(""), fmt.Errorf("no validation defined for %#v", obj)))
 	}
-	return errors
+	return docs, nil
 }
 
-// Walks inDir for any json/yaml files. Converts yaml to json, and calls fn for
-// each file found with the contents in data.
-func walkConfigFiles(inDir string, fn func(name, path string, data [][]byte)) error {
+// Walks inDir recursively for any json/yaml files, skipping the files and
+// directories ignored by the directoryConfigFile of their directory. Converts
+// yaml to json, and calls fn for each file found with the contents in data
+// and the configuration of its directory.
+func walkConfigFiles(inDir string, fn func(name, path string, data [][]byte, config *directoryConfig)) error {
+	configs := map[string]*directoryConfig{}
 	return filepath.Walk(inDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
-		if info.IsDir() && path != inDir {
-			return filepath.SkipDir
+		if info.IsDir() {
+			parent := configs[filepath.Dir(path)]
+			if path != inDir && parent.ignored(info.Name()) {
+				return filepath.SkipDir
+			}
+			config, err := loadDirectoryConfig(path, parent)
+			if err != nil {
+				return err
+			}
+			configs[path] = config
+			return nil
 		}
 
 		file := filepath.Base(path)
+		config := configs[filepath.Dir(path)]
+		if file == directoryConfigFile || config.ignored(file) {
+			return nil
+		}
 		if ext := filepath.Ext(file); ext == ".json" || ext == ".yaml" {
 			//glog.Infof("Testing %s", path)
 			data, err := ioutil.ReadFile(path)
@@ -229,508 +251,229 @@ func walkConfigFiles(inDir string, fn func(name, path string, data [][]byte)) er
 			}
 			// workaround for Jekyllr limit
 			if bytes.HasPrefix(data, []byte("---\n")) {
-				return fmt.Errorf("YAML file cannot start with \"---\", please remove the first line")
+				return fmt.Errorf("%s: YAML file cannot start with \"---\", please remove the first line", path)
 			}
 			name := strings.TrimSuffix(file, ext)
 
 			var docs [][]byte
 			if ext == ".yaml" {
 				// YAML can contain multiple documents.
-				splitter := yaml.NewYAMLReader(bufio.NewReader(bytes.NewBuffer(data)))
-				for {
-					doc, err := splitter.Read()
-					if err == io.EOF {
-						break
-					}
-					if err != nil {
-						return fmt.Errorf("%s: %v", path, err)
-					}
-					out, err := yaml.ToJSON(doc)
-					if err != nil {
-						return fmt.Errorf("%s: %v", path, err)
-					}
-					// deal with "empty" document (e.g. pure comments)
-					if string(out) != "null" {
-						docs = append(docs, out)
-					}
+				docs, err = splitDocuments(data)
+				if err != nil {
+					return fmt.Errorf("%s: %v", path, err)
 				}
 			} else {
 				docs = append(docs, data)
 			}
 
-			fn(name, path, docs)
+			fn(name, path, docs, config)
 		}
 		return nil
 	})
 }
 
+// TestExampleObjectSchemas validates every example in a subtest named after
+// its path, e.g. docs/concepts/workloads/pods/pod.yaml, which can be selected
+// with -run TestExampleObjectSchemas/docs/concepts/workloads/pods/pod.yaml.
+// The subtests run in parallel.
 func TestExampleObjectSchemas(t *testing.T) {
-	// Please help maintain the alphabeta order in the map
-	cases := map[string]map[string][]runtime.Object{
-		"../docs/admin/high-availability": {
-			"etcd":                    {&api.Pod{}},
-			"kube-apiserver":          {&api.Pod{}},
-			"kube-controller-manager": {&api.Pod{}},
-			"kube-scheduler":          {&api.Pod{}},
-			"podmaster":               {&api.Pod{}},
-		},
-		"../docs/admin/limitrange": {
-			"invalid-pod": {&api.Pod{}},
-			"limits":      {&api.LimitRange{}},
-			"namespace":   {&api.Namespace{}},
-			"valid-pod":   {&api.Pod{}},
-		},
-		"../docs/admin/multiple-schedulers": {
-			"my-scheduler": {&extensions.Deployment{}},
-			"pod1":         {&api.Pod{}},
-			"pod2":         {&api.Pod{}},
-			"pod3":         {&api.Pod{}},
-		},
-		"../docs/admin/resourcequota": {
-			"best-effort":       {&api.ResourceQuota{}},
-			"compute-resources": {&api.ResourceQuota{}},
-			"limits":            {&api.LimitRange{}},
-			"namespace":         {&api.Namespace{}},
-			"not-best-effort":   {&api.ResourceQuota{}},
-			"object-counts":     {&api.ResourceQuota{}},
-		},
-		"../docs/concepts/cluster-administration": {
-			"counter-pod":                             {&api.Pod{}},
-			"fluentd-sidecar-config":                  {&api.ConfigMap{}},
-			"nginx-app":                               {&api.Service{}, &extensions.Deployment{}},
-			"nginx-deployment":                        {&extensions.Deployment{}},
-			"two-files-counter-pod":                   {&api.Pod{}},
-			"two-files-counter-pod-agent-sidecar":     {&api.Pod{}},
-			"two-files-counter-pod-streaming-sidecar": {&api.Pod{}},
-		},
-		"../docs/concepts/cluster-administration/nginx": {
-			"nginx-deployment": {&extensions.Deployment{}},
-			"nginx-svc":        {&api.Service{}},
-		},
-		"../docs/concepts/configuration": {
-			"commands": {&api.Pod{}},
-			"pod":      {&api.Pod{}},
-			"pod-with-node-affinity": {&api.Pod{}},
-			"pod-with-pod-affinity":  {&api.Pod{}},
-		},
-		"../docs/concepts/overview/working-with-objects": {
-			"nginx-deployment": {&extensions.Deployment{}},
-		},
-		"../docs/concepts/policy": {
-			"privileged-psp": {&extensions.PodSecurityPolicy{}},
-			"restricted-psp": {&extensions.PodSecurityPolicy{}},
-			"example-psp":    {&extensions.PodSecurityPolicy{}},
-		},
-		"../docs/concepts/services-networking": {
-			"curlpod":          {&extensions.Deployment{}},
-			"custom-dns":       {&api.Pod{}},
-			"hostaliases-pod":  {&api.Pod{}},
-			"ingress":          {&extensions.Ingress{}},
-			"nginx-secure-app": {&api.Service{}, &extensions.Deployment{}},
-			"nginx-svc":        {&api.Service{}},
-			"run-my-nginx":     {&extensions.Deployment{}},
-		},
-		"../docs/concepts/workloads/controllers": {
-			"cronjob":          {&batch.CronJob{}},
-			"daemonset":        {&extensions.DaemonSet{}},
-			"frontend":         {&extensions.ReplicaSet{}},
-			"hpa-rs":           {&autoscaling.HorizontalPodAutoscaler{}},
-			"job":              {&batch.Job{}},
-			"my-repset":        {&extensions.ReplicaSet{}},
-			"nginx-deployment": {&extensions.Deployment{}},
-			"replication":      {&api.ReplicationController{}},
-		},
-		"../docs/tasks/access-application-cluster": {
-			"frontend":          {&api.Service{}, &extensions.Deployment{}},
-			"hello-service":     {&api.Service{}},
-			"hello":             {&extensions.Deployment{}},
-			"redis-master":      {&api.Pod{}},
-			"two-container-pod": {&api.Pod{}},
-		},
-		"../docs/tasks/administer-cluster": {
-			"busybox": {&api.Pod{}},
-			"cloud-controller-manager-daemonset-example": {&api.ServiceAccount{}, &rbac.ClusterRoleBinding{}, &extensions.DaemonSet{}},
-			"cpu-constraints":                            {&api.LimitRange{}},
-			"cpu-constraints-pod":                        {&api.Pod{}},
-			"cpu-constraints-pod-2":                      {&api.Pod{}},
-			"cpu-constraints-pod-3":                      {&api.Pod{}},
-			"cpu-constraints-pod-4":                      {&api.Pod{}},
-			"cpu-defaults":                               {&api.LimitRange{}},
-			"cpu-defaults-pod":                           {&api.Pod{}},
-			"cpu-defaults-pod-2":                         {&api.Pod{}},
-			"cpu-defaults-pod-3":                         {&api.Pod{}},
-			"dns-horizontal-autoscaler":                  {&extensions.Deployment{}},
-			"memory-constraints":                         {&api.LimitRange{}},
-			"memory-constraints-pod":                     {&api.Pod{}},
-			"memory-constraints-pod-2":                   {&api.Pod{}},
-			"memory-constraints-pod-3":                   {&api.Pod{}},
-			"memory-constraints-pod-4":                   {&api.Pod{}},
-			"memory-defaults":                            {&api.LimitRange{}},
-			"memory-defaults-pod":                        {&api.Pod{}},
-			"memory-defaults-pod-2":                      {&api.Pod{}},
-			"memory-defaults-pod-3":                      {&api.Pod{}},
-			"my-scheduler":                               {&extensions.Deployment{}},
-			"namespace-dev":                              {&api.Namespace{}},
-			"namespace-prod":                             {&api.Namespace{}},
-			"persistent-volume-label-initializer-config": {&admissionregistration.InitializerConfiguration{}},
-			"pod1":                 {&api.Pod{}},
-			"pod2":                 {&api.Pod{}},
-			"pod3":                 {&api.Pod{}},
-			"quota-mem-cpu":        {&api.ResourceQuota{}},
-			"quota-mem-cpu-pod":    {&api.Pod{}},
-			"quota-mem-cpu-pod-2":  {&api.Pod{}},
-			"quota-objects":        {&api.ResourceQuota{}},
-			"quota-objects-pvc":    {&api.PersistentVolumeClaim{}},
-			"quota-objects-pvc-2":  {&api.PersistentVolumeClaim{}},
-			"quota-pod":            {&api.ResourceQuota{}},
-			"quota-pod-deployment": {&extensions.Deployment{}},
-			"quota-pvc-2":          {&api.PersistentVolumeClaim{}},
-		},
-		"../docs/tasks/configure-pod-container": {
-			"cpu-request-limit":       {&api.Pod{}},
-			"cpu-request-limit-2":     {&api.Pod{}},
-			"exec-liveness":           {&api.Pod{}},
-			"extended-resource-pod":   {&api.Pod{}},
-			"extended-resource-pod-2": {&api.Pod{}},
-			"http-liveness":           {&api.Pod{}},
-			"init-containers":         {&api.Pod{}},
-			"lifecycle-events":        {&api.Pod{}},
-			"mem-limit-range":         {&api.LimitRange{}},
-			"memory-request-limit":    {&api.Pod{}},
-			"memory-request-limit-2":  {&api.Pod{}},
-			"memory-request-limit-3":  {&api.Pod{}},
-			"oir-pod":                 {&api.Pod{}},
-			"oir-pod-2":               {&api.Pod{}},
-			"pod":                     {&api.Pod{}},
-			"pod-redis":               {&api.Pod{}},
-			"private-reg-pod":         {&api.Pod{}},
-			"projected-volume":        {&api.Pod{}},
-			"qos-pod":                 {&api.Pod{}},
-			"qos-pod-2":               {&api.Pod{}},
-			"qos-pod-3":               {&api.Pod{}},
-			"qos-pod-4":               {&api.Pod{}},
-			"rq-compute-resources":    {&api.ResourceQuota{}},
-			"security-context":        {&api.Pod{}},
-			"security-context-2":      {&api.Pod{}},
-			"security-context-3":      {&api.Pod{}},
-			"security-context-4":      {&api.Pod{}},
-			"share-process-namespace": {&api.Pod{}},
-			"task-pv-claim":           {&api.PersistentVolumeClaim{}},
-			"task-pv-pod":             {&api.Pod{}},
-			"task-pv-volume":          {&api.PersistentVolume{}},
-			"tcp-liveness-readiness":  {&api.Pod{}},
-		},
-		"../docs/tasks/debug-application-cluster": {
-			"counter-pod":           {&api.Pod{}},
-			"event-exporter-deploy": {&api.ServiceAccount{}, &rbac.ClusterRoleBinding{}, &extensions.Deployment{}},
-			"fluentd-gcp-configmap": {&api.ConfigMap{}},
-			"fluentd-gcp-ds":        {&extensions.DaemonSet{}},
-			"nginx-dep":             {&extensions.Deployment{}},
-			"shell-demo":            {&api.Pod{}},
-			"node-problem-detector":        {&extensions.DaemonSet{}},
-			"node-problem-detector-configmap":        {&extensions.DaemonSet{}},
-			"termination":           {&api.Pod{}},
-		},
-		// TODO: decide whether federation examples should be added
-		"../docs/tasks/inject-data-application": {
-			"commands":                    {&api.Pod{}},
-			"dapi-envars-container":       {&api.Pod{}},
-			"dapi-envars-pod":             {&api.Pod{}},
-			"dapi-volume":                 {&api.Pod{}},
-			"dapi-volume-resources":       {&api.Pod{}},
-			"envars":                      {&api.Pod{}},
-			"podpreset-allow-db":          {&settings.PodPreset{}},
-			"podpreset-allow-db-merged":   {&api.Pod{}},
-			"podpreset-configmap":         {&api.ConfigMap{}},
-			"podpreset-conflict-pod":      {&api.Pod{}},
-			"podpreset-conflict-preset":   {&settings.PodPreset{}},
-			"podpreset-merged":            {&api.Pod{}},
-			"podpreset-multi-merged":      {&api.Pod{}},
-			"podpreset-pod":               {&api.Pod{}},
-			"podpreset-preset":            {&settings.PodPreset{}},
-			"podpreset-proxy":             {&settings.PodPreset{}},
-			"podpreset-replicaset-merged": {&api.Pod{}},
-			"podpreset-replicaset":        {&extensions.ReplicaSet{}},
-			"secret":                      {&api.Secret{}},
-			"secret-envars-pod":           {&api.Pod{}},
-			"secret-pod":                  {&api.Pod{}},
-		},
-		"../docs/tasks/job": {
-			"job": {&batch.Job{}},
-		},
-		"../docs/tasks/job/coarse-parallel-processing-work-queue": {
-			"job": {&batch.Job{}},
-		},
-		"../docs/tasks/job/fine-parallel-processing-work-queue": {
-			"job":           {&batch.Job{}},
-			"redis-pod":     {&api.Pod{}},
-			"redis-service": {&api.Service{}},
-		},
-		"../docs/tasks/run-application": {
-			"deployment":            {&extensions.Deployment{}},
-			"deployment-patch-demo": {&extensions.Deployment{}},
-			"deployment-scale":      {&extensions.Deployment{}},
-			"deployment-update":     {&extensions.Deployment{}},
-			"hpa-php-apache":        {&autoscaling.HorizontalPodAutoscaler{}},
-			"mysql-configmap":       {&api.ConfigMap{}},
-			"mysql-deployment":      {&api.Service{}, &api.PersistentVolumeClaim{}, &extensions.Deployment{}},
-			"mysql-services":        {&api.Service{}, &api.Service{}},
-			"mysql-statefulset":     {&apps.StatefulSet{}},
-		},
-		"../docs/tutorials/clusters": {
-			"hello-apparmor-pod": {&api.Pod{}},
-			"my-scheduler":       {&extensions.Deployment{}},
-		},
-		"../docs/tutorials/configuration/configmap/redis": {
-			"redis-pod": {&api.Pod{}},
-		},
-		"../docs/concepts/overview/object-management-kubectl": {
-			"simple_deployment": {&extensions.Deployment{}},
-			"update_deployment": {&extensions.Deployment{}},
-		},
-		"../docs/tutorials/stateful-application": {
-			"web":       {&api.Service{}, &apps.StatefulSet{}},
-			"webp":      {&api.Service{}, &apps.StatefulSet{}},
-			"zookeeper": {&api.Service{}, &api.Service{}, &policy.PodDisruptionBudget{}, &apps.StatefulSet{}},
-		},
-		"../docs/tutorials/stateful-application/cassandra": {
-			"cassandra-service":     {&api.Service{}},
-			"cassandra-statefulset": {&apps.StatefulSet{}, &storage.StorageClass{}},
-		},
-		"../docs/tutorials/stateful-application/mysql-wordpress-persistent-volume": {
-			"local-volumes":        {&api.PersistentVolume{}, &api.PersistentVolume{}},
-			"mysql-deployment":     {&api.Service{}, &api.PersistentVolumeClaim{}, &extensions.Deployment{}},
-			"wordpress-deployment": {&api.Service{}, &api.PersistentVolumeClaim{}, &extensions.Deployment{}},
-		},
-		"../docs/tutorials/stateless-application": {
-			"deployment":        {&extensions.Deployment{}},
-			"deployment-scale":  {&extensions.Deployment{}},
-			"deployment-update": {&extensions.Deployment{}},
-		},
-		"../docs/tutorials/stateless-application/guestbook": {
-			"frontend-deployment":     {&extensions.Deployment{}},
-			"frontend-service":        {&api.Service{}},
-			"redis-master-deployment": {&extensions.Deployment{}},
-			"redis-master-service":    {&api.Service{}},
-			"redis-slave-deployment":  {&extensions.Deployment{}},
-			"redis-slave-service":     {&api.Service{}},
-		},
-		"../docs/user-guide": {
-			"bad-nginx-deployment":       {&extensions.Deployment{}},
-			"curlpod":                    {&extensions.Deployment{}},
-			"deployment":                 {&extensions.Deployment{}},
-			"ingress":                    {&extensions.Ingress{}},
-			"job":                        {&batch.Job{}},
-			"multi-pod":                  {&api.Pod{}, &api.Pod{}},
-			"new-nginx-deployment":       {&extensions.Deployment{}},
-			"nginx-app":                  {&api.Service{}, &extensions.Deployment{}},
-			"nginx-init-containers":      {&api.Pod{}},
-			"nginx-lifecycle-deployment": {&extensions.Deployment{}},
-			"nginx-probe-deployment":     {&extensions.Deployment{}},
-			"nginx-secure-app":           {&api.Service{}, &extensions.Deployment{}},
-			"nginx-svc":                  {&api.Service{}},
-			"pod":                        {&api.Pod{}},
-			"pod-w-message":              {&api.Pod{}},
-			"redis-deployment":           {&extensions.Deployment{}},
-			"redis-resource-deployment":  {&extensions.Deployment{}},
-			"redis-secret-deployment":    {&extensions.Deployment{}},
-			"run-my-nginx":               {&extensions.Deployment{}},
-		},
-		"../docs/user-guide/configmap": {
-			"command-pod":    {&api.Pod{}},
-			"configmap":      {&api.ConfigMap{}},
-			"env-pod":        {&api.Pod{}},
-			"mount-file-pod": {&api.Pod{}},
-			"volume-pod":     {&api.Pod{}},
-		},
-		"../docs/user-guide/liveness": {
-			"exec-liveness":            {&api.Pod{}},
-			"http-liveness":            {&api.Pod{}},
-			"http-liveness-named-port": {&api.Pod{}},
-		},
-		"../docs/user-guide/node-selection": {
-			"pod": {&api.Pod{}},
-			"pod-with-node-affinity": {&api.Pod{}},
-			"pod-with-pod-affinity":  {&api.Pod{}},
-		},
-		"../docs/user-guide/replicasets": {
-			"frontend":    {&extensions.ReplicaSet{}},
-			"hpa-rs":      {&autoscaling.HorizontalPodAutoscaler{}},
-			"redis-slave": {&extensions.ReplicaSet{}},
-		},
-		"../docs/user-guide/secrets": {
-			"secret":         {&api.Secret{}},
-			"secret-env-pod": {&api.Pod{}},
-			"secret-pod":     {&api.Pod{}},
-		},
-		"../docs/user-guide/services": {
-			"load-balancer-sample": {&api.Service{}},
-			"service-sample":       {&api.Service{}},
-		},
-		"../docs/user-guide/update-demo": {
-			"kitten-rc":   {&api.ReplicationController{}},
-			"nautilus-rc": {&api.ReplicationController{}},
-		},
-		"../docs/user-guide/walkthrough": {
-			"deployment":                      {&extensions.Deployment{}},
-			"deployment-update":               {&extensions.Deployment{}},
-			"pod-nginx":                       {&api.Pod{}},
-			"pod-nginx-with-label":            {&api.Pod{}},
-			"pod-redis":                       {&api.Pod{}},
-			"pod-with-http-healthcheck":       {&api.Pod{}},
-			"pod-with-tcp-socket-healthcheck": {&api.Pod{}},
-			"podtemplate":                     {&api.PodTemplate{}},
-			"service":                         {&api.Service{}},
-		},
+	tested := 0
+	err := walkConfigFiles(examplesRoot, func(name, path string, docs [][]byte, config *directoryConfig) {
+		tested++
+		example := config.example(filepath.Base(path))
+		t.Run(subtestName(path), func(t *testing.T) {
+			t.Parallel()
+			runCached(t, path, example, func() {
+				validateExampleFile(t, path, docs, example)
+			})
+		})
+	})
+	if err != nil {
+		t.Errorf("Expected no error, Got %v on Path %v", err, examplesRoot)
 	}
-
-	filesIgnore := map[string]map[string]bool{
-		"../docs/tasks/debug-application-cluster": {
-			"audit-policy": true,
-		},
+	if tested == 0 {
+		t.Errorf("Directory %v: no examples found", examplesRoot)
 	}
-	capabilities.SetForTests(capabilities.Capabilities{
-		AllowPrivileged: true,
-	})
-	// PodShareProcessNamespace needed for example share-process-namespace.yaml
-	utilfeature.DefaultFeatureGate.Set("PodShareProcessNamespace=true")
+}
 
-	for path, expected := range cases {
-		tested := 0
-		numExpected := 0
-		err := walkConfigFiles(path, func(name, path string, docs [][]byte) {
-			expectedTypes, found := expected[name]
-			if !found {
-				p := filepath.Dir(path)
-				if files, ok := filesIgnore[p]; ok {
-					if files[name] {
-						return
-					}
-				}
-				t.Errorf("%s: %s does not have a test case defined", path, name)
-				return
-			}
-			numExpected += len(expectedTypes)
-			if len(expectedTypes) != len(docs) {
-				t.Errorf("%s: number of expected types (%v) doesn't match number of docs in YAML (%v)", path, len(expectedTypes), len(docs))
-				return
-			}
-			for i, data := range docs {
-				expectedType := expectedTypes[i]
-				tested++
-				if expectedType == nil {
-					t.Logf("skipping : %s/%s\n", path, name)
-					return
-				}
-				if strings.Contains(name, "scheduler-policy-config") {
-					if err := runtime.DecodeInto(schedulerapilatest.Codec, data, expectedType); err != nil {
-						t.Errorf("%s did not decode correctly: %v\n%s", path, err, string(data))
-						return
-					}
-					// TODO: Add validate method for
-					// &schedulerapi.Policy, and remove this
-					// special case
-				} else {
-					codec, err := testapi.GetCodecForObject(expectedType)
+// validateExampleFile decodes and validates the documents docs of the example
+// file at path, configured by example.
+func validateExampleFile(t *testing.T, path string, docs [][]byte, example exampleConfig) {
+	if len(docs) == 0 {
+		reportError(t, finding{File: path}, "file contains no documents")
+		return
+	}
+	var kinds []string
+	for _, data := range docs {
+		typeMeta, _ := documentTypeMeta(data)
+		kinds = append(kinds, typeMeta.Kind)
+	}
+	if len(example.Kinds) > 0 && !reflect.DeepEqual(example.Kinds, kinds) {
+		reportError(t, finding{File: path}, "expected kinds %v as declared in %s, got %v", example.Kinds, directoryConfigFile, kinds)
+	}
+	checkFeatureStates(t, path, example.FeatureGates)
+	err := withExampleConfig(example, func() {
+		for i, data := range docs {
+			f := finding{File: path, Document: i, Kind: kinds[i]}
+			src := fileSourceDocument(path, i)
+			typeMeta, _ := documentTypeMeta(data)
+			if !legacyscheme.Scheme.Recognizes(schema.FromAPIVersionAndKind(typeMeta.APIVersion, typeMeta.Kind)) {
+				if config, found := findComponentConfig(typeMeta); found {
+					errors, err := config.Validate(data)
 					if err != nil {
-						t.Errorf("Could not get codec for %s: %s", expectedType, err)
-					}
-					if err := runtime.DecodeInto(codec, data, expectedType); err != nil {
-						t.Errorf("%s did not decode correctly: %v\n%s", path, err, string(data))
-						return
-					}
-					if errors := validateObject(expectedType); len(errors) > 0 {
-						t.Errorf("%s did not validate correctly: %v", path, errors)
+						reportError(t, f, "did not decode correctly as a %s configuration: %v\n%s", config.Component, err, string(data))
+						continue
 					}
+					reportValidation(t, f, src, filepath.Dir(path), example.ExpectInvalid, nil, errors)
+					continue
+				}
+				if crd, found := directoryCustomResourceDefinitions(filepath.Dir(path)).lookup(typeMeta); found {
+					reportValidation(t, f, src, filepath.Dir(path), example.ExpectInvalid, nil, validateCustomResource(crd, data))
+					continue
 				}
 			}
-		})
-		if err != nil {
-			t.Errorf("Expected no error, Got %v on Path %v", err, path)
-		}
-		if tested != numExpected {
-			t.Errorf("Directory %v: Expected %d examples, Got %d", path, len(expected), tested)
+			obj, err := decodeDocument(data)
+			if err != nil {
+				reportError(t, f, "did not decode correctly: %v\n%s", err, string(data))
+				continue
+			}
+			reportValidation(t, f, src, filepath.Dir(path), example.ExpectInvalid, obj, validateObject(obj))
+			reportStrictDecoding(t, f, src, data, obj, example.LenientDecoding)
 		}
+	})
+	if err != nil {
+		reportError(t, finding{File: path}, "invalid feature gates in %s: %v", directoryConfigFile, err)
 	}
 }
 
-// This regex is tricky, but it works.  For future me, here is the decode:
-//
-// Flags: (?ms) = multiline match, allow . to match \n
-// 1) Look for a line that starts with ``` (a markdown code block)
-// 2) (?: ... ) = non-capturing group
-// 3) (P<name>) = capture group as "name"
-// 4) Look for #1 followed by either:
-// 4a)    "yaml" followed by any word-characters followed by a newline (e.g. ```yamlfoo\n)
-// 4b)    "any word-characters followed by a newline (e.g. ```json\n)
-// 5) Look for either:
-// 5a)    #4a followed by one or more characters (non-greedy)
-// 5b)    #4b followed by { followed by one or more characters (non-greedy) followed by }
-// 6) Look for #5 followed by a newline followed by ``` (end of the code block)
-//
-// This could probably be simplified, but is already too delicate.  Before any
-// real changes, we should have a test case that just tests this regex.
-var sampleRegexp = regexp.MustCompile("(?ms)^```(?:(?P<type>yaml)\\w*\\n(?P<content>.+?)|\\w*\\n(?P<content>\\{.+?\\}))\\n^```")
+// markdownRoots are the directories searched recursively for Markdown pages
+// whose yaml and json code blocks are validated by TestReadme.
+var markdownRoots = []string{"../docs", "../cn"}
+
 var subsetRegexp = regexp.MustCompile("(?ms)\\.{3}")
 
+// TestReadme validates the code blocks of every page in a subtest named after
+// its path, like TestExampleObjectSchemas.
 func TestReadme(t *testing.T) {
-	paths := []struct {
-		file         string
-		expectedType []runtime.Object
-	}{
-		{"../docs/concepts/storage/volumes.md", []runtime.Object{&api.Pod{}}},
-	}
-
-	for _, path := range paths {
-		data, err := ioutil.ReadFile(path.file)
+	tested := 0
+	for _, root := range markdownRoots {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || filepath.Ext(path) != ".md" {
+				return nil
+			}
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				reportError(t, finding{File: path}, "unable to read file: %v", err)
+				return nil
+			}
+			blocks := extractCodeBlocks(data)
+			if len(blocks) == 0 {
+				return nil
+			}
+			tested++
+			t.Run(subtestName(path), func(t *testing.T) {
+				t.Parallel()
+				runCached(t, path, exampleConfig{}, func() {
+					// Custom resources are validated against the latest
+					// definition on the page, or else in its directory.
+					crds := customResourceDefinitions{}
+					for gk, crd := range directoryCustomResourceDefinitions(filepath.Dir(path)) {
+						crds[gk] = crd
+					}
+					for _, block := range blocks {
+						validateCodeBlock(t, path, block, crds)
+					}
+				})
+			})
+			return nil
+		})
 		if err != nil {
-			t.Errorf("Unable to read file %s: %v", path, err)
-			continue
+			t.Errorf("Expected no error, Got %v on Path %v", err, root)
 		}
+	}
+	if tested == 0 {
+		t.Errorf("No code blocks found in %v", markdownRoots)
+	}
+}
 
-		matches := sampleRegexp.FindAllStringSubmatch(string(data), -1)
-		if matches == nil {
-			continue
-		}
-		ix := 0
-		for _, match := range matches {
-			var content, subtype string
-			for i, name := range sampleRegexp.SubexpNames() {
-				if name == "type" {
-					subtype = match[i]
+// validateCodeBlock decodes and validates every Kubernetes object in a yaml
+// or json code block of the Markdown file at path. Custom resources are
+// validated against their definition in crds, to which the definitions of the
+// block are added. Blocks that are marked with skipValidationMarkerPrefix,
+// elide content with "..." or that are fragments without apiVersion and kind
+// are skipped.
+func validateCodeBlock(t *testing.T, path string, block codeBlock, crds customResourceDefinitions) {
+	if block.Lang != "yaml" && block.Lang != "yml" && block.Lang != "json" {
+		return
+	}
+	location := fmt.Sprintf("%s:%d", path, block.Line)
+	for _, marker := range block.InvalidMarkers {
+		reportError(t, finding{File: path, Line: block.Line}, "%s requires a reason, e.g. %s placeholder values -->", marker, skipValidationMarkerPrefix)
+	}
+	if block.SkipReason != "" {
+		t.Logf("skipping (%s): %s", location, block.SkipReason)
+		return
+	}
+	if subsetRegexp.MatchString(block.Content) {
+		t.Logf("skipping (%s): content is elided with \"...\"", location)
+		return
+	}
+
+	docs, err := splitDocuments([]byte(block.Content))
+	if err != nil {
+		reportError(t, finding{File: path, Line: block.Line}, "could not be converted to JSON: %v\n%s", err, block.Content)
+		return
+	}
+	// Positions in the block are relative to the line of the opening fence.
+	sources := parseSourceDocuments([]byte(block.Content), block.Line, block.Indent)
+	if len(sources) != len(docs) {
+		sources = make([]*sourceDocument, len(docs))
+	}
+	crds.addDocuments(docs)
+	err = withExampleConfig(exampleConfig{FeatureGates: block.FeatureGates}, func() {
+		for i, data := range docs {
+			typeMeta, err := documentTypeMeta(data)
+			if err != nil || typeMeta.APIVersion == "" || typeMeta.Kind == "" {
+				// Not an object, e.g. a fragment of a spec.
+				continue
+			}
+			f := finding{File: path, Document: i, Line: block.Line, Kind: typeMeta.Kind}
+			if !legacyscheme.Scheme.Recognizes(schema.FromAPIVersionAndKind(typeMeta.APIVersion, typeMeta.Kind)) {
+				if config, found := findComponentConfig(typeMeta); found {
+					errors, err := config.Validate(data)
+					if err != nil {
+						reportError(t, f, "did not decode correctly as a %s configuration: %v\n%s", config.Component, err, block.Content)
+						continue
+					}
+					reportValidation(t, f, sources[i], filepath.Dir(path), block.ExpectInvalid, nil, errors)
+					continue
 				}
-				if name == "content" && match[i] != "" {
-					content = match[i]
+				if crd, found := crds.lookup(typeMeta); found {
+					reportValidation(t, f, sources[i], filepath.Dir(path), block.ExpectInvalid, nil, validateCustomResource(crd, data))
+					continue
 				}
-			}
-			if subtype == "yaml" && subsetRegexp.FindString(content) != "" {
-				t.Logf("skipping (%s): \n%s", subtype, content)
+				// Not served by the API server, e.g. a kubeconfig file.
+				t.Logf("skipping (%s): %s %s is not a known API object", location, typeMeta.APIVersion, typeMeta.Kind)
 				continue
 			}
-
-			var expectedType runtime.Object
-			if len(path.expectedType) == 1 {
-				expectedType = path.expectedType[0]
-			} else {
-				expectedType = path.expectedType[ix]
-				ix++
-			}
-			json, err := yaml.ToJSON([]byte(content))
+			obj, err := decodeDocument(data)
 			if err != nil {
-				t.Errorf("%s could not be converted to JSON: %v\n%s", path, err, string(content))
-			}
-			if err := runtime.DecodeInto(testapi.Default.Codec(), json, expectedType); err != nil {
-				t.Errorf("%s did not decode correctly: %v\n%s", path, err, string(content))
+				reportError(t, f, "did not decode correctly: %v\n%s", err, block.Content)
 				continue
 			}
-			if errors := validateObject(expectedType); len(errors) > 0 {
-				t.Errorf("%s did not validate correctly: %v", path, errors)
-			}
-			_, err = runtime.Encode(testapi.Default.Codec(), expectedType)
-			if err != nil {
-				t.Errorf("Could not encode object: %v", err)
-				continue
+			errors := validateObject(obj)
+			if len(errors) == 1 && errors[0].Type == field.ErrorTypeInternal {
+				t.Logf("skipping validation (%s): %v", location, errors)
+			} else {
+				reportValidation(t, f, sources[i], filepath.Dir(path), block.ExpectInvalid, obj, errors)
 			}
+			reportStrictDecoding(t, f, sources[i], data, obj, block.Lenient)
 		}
+	})
+	if err != nil {
+		reportError(t, finding{File: path, Line: block.Line}, "invalid feature gates in %s: %v", featureGatesMarkerPrefix, err)
 	}
 }
@@ -0,0 +1,225 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package examples_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
+	"k8s.io/kubernetes/pkg/capabilities"
+)
+
+// exampleConfigLock is held for writing while the feature gates and
+// capabilities of an example are changed, and for reading by the examples
+// that run concurrently with the defaults.
+var exampleConfigLock sync.RWMutex
+
+// withExampleConfig enables the feature gates and capabilities needed by an
+// example while fn runs and restores their previous values afterwards.
+func withExampleConfig(example exampleConfig, fn func()) error {
+	if len(example.FeatureGates) == 0 && !example.AllowPrivileged {
+		exampleConfigLock.RLock()
+		defer exampleConfigLock.RUnlock()
+		fn()
+		return nil
+	}
+	exampleConfigLock.Lock()
+	defer exampleConfigLock.Unlock()
+	previousCapabilities := capabilities.Get()
+	defer capabilities.SetForTests(previousCapabilities)
+	c := previousCapabilities
+	c.AllowPrivileged = example.AllowPrivileged
+	capabilities.SetForTests(c)
+
+	previous := map[string]bool{}
+	defer func() {
+		for name, enabled := range previous {
+			utilfeature.DefaultFeatureGate.Set(fmt.Sprintf("%s=%t", name, enabled))
+		}
+	}()
+	for _, gate := range example.FeatureGates {
+		name := strings.TrimSpace(strings.SplitN(gate, "=", 2)[0])
+		if _, saved := previous[name]; !saved {
+			previous[name] = utilfeature.DefaultFeatureGate.Enabled(utilfeature.Feature(name))
+		}
+		if err := utilfeature.DefaultFeatureGate.Set(gate); err != nil {
+			return err
+		}
+	}
+	fn()
+	return nil
+}
+
+// knownFeatureRegexp matches the description of a feature gate returned by
+// utilfeature.DefaultFeatureGate.KnownFeatures(), e.g.
+// "PodShareProcessNamespace=true|false (ALPHA - default=false)".
+var knownFeatureRegexp = regexp.MustCompile(`^(\w+)=true\|false \((?:(\w+) - )?default=(true|false)\)$`)
+
+// featureGateSpec returns the release stage, e.g. ALPHA, and the default
+// value of the feature gate name. The stage of GA features is empty.
+func featureGateSpec(name string) (stage string, enabledByDefault bool, found bool) {
+	for _, known := range utilfeature.DefaultFeatureGate.KnownFeatures() {
+		m := knownFeatureRegexp.FindStringSubmatch(known)
+		if m != nil && m[1] == name {
+			return m[2], m[3] == "true", true
+		}
+	}
+	return "", false, false
+}
+
+// featureStateIncludes are the includes that state the release stage of the
+// feature described by a page.
+var featureStateIncludes = map[string]string{
+	"ALPHA": "feature-state-alpha.md",
+	"BETA":  "feature-state-beta.md",
+}
+
+// referencingPages returns the Markdown pages in the directory of the
+// example at path, or in one of its parents up to examplesRoot, that include
+// it with code.html.
+func referencingPages(path string) ([]string, error) {
+	var pages []string
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil, err
+		}
+		files, err := filepath.Glob(filepath.Join(dir, "*.md"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			for _, include := range extractCodeIncludes(data) {
+				if filepath.Clean(filepath.FromSlash(include.File)) == rel {
+					pages = append(pages, file)
+					break
+				}
+			}
+		}
+		if filepath.Clean(dir) == filepath.Clean(examplesRoot) || dir == "." || dir == string(filepath.Separator) {
+			break
+		}
+	}
+	return pages, nil
+}
+
+// checkFeatureStates verifies that the pages that include the example at path
+// state the release stage of the feature gates it needs, e.g. with
+// {% include feature-state-alpha.md %} for an alpha feature. Gates that are
+// set to their default value are not checked.
+func checkFeatureStates(t *testing.T, path string, gates []string) {
+	var required []string
+	for _, gate := range gates {
+		parts := strings.SplitN(gate, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		value := true
+		if len(parts) == 2 {
+			value, _ = strconv.ParseBool(strings.TrimSpace(parts[1]))
+		}
+		stage, enabledByDefault, found := featureGateSpec(name)
+		if !found || value == enabledByDefault || featureStateIncludes[stage] == "" {
+			continue
+		}
+		required = append(required, name, featureStateIncludes[stage])
+	}
+	if len(required) == 0 {
+		return
+	}
+
+	pages, err := referencingPages(path)
+	if err != nil {
+		reportError(t, finding{File: path}, "unable to find the pages that include the example: %v", err)
+		return
+	}
+	for _, page := range pages {
+		data, err := ioutil.ReadFile(page)
+		if err != nil {
+			reportError(t, finding{File: page}, "unable to read file: %v", err)
+			continue
+		}
+		for i := 0; i < len(required); i += 2 {
+			include := regexp.MustCompile(`\{%\s*include\s+` + regexp.QuoteMeta(required[i+1]) + `\s*%\}`)
+			if !include.Match(data) {
+				f := finding{File: path, Field: "featureGates"}
+				reportError(t, f, "needs the %s feature gate, but %s, which includes the example, does not contain {%% include %s %%}", required[i], page, required[i+1])
+			}
+		}
+	}
+}
+
+func TestWithExampleConfig(t *testing.T) {
+	const gate = "PodShareProcessNamespace"
+	before := utilfeature.DefaultFeatureGate.Enabled(gate)
+	beforePrivileged := capabilities.Get().AllowPrivileged
+	var enabled, privileged bool
+	example := exampleConfig{
+		FeatureGates:    []string{fmt.Sprintf("%s=%t", gate, !before)},
+		AllowPrivileged: !beforePrivileged,
+	}
+	err := withExampleConfig(example, func() {
+		enabled = utilfeature.DefaultFeatureGate.Enabled(gate)
+		privileged = capabilities.Get().AllowPrivileged
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if enabled == before {
+		t.Errorf("Expected %s to be %t while fn runs", gate, !before)
+	}
+	if privileged == beforePrivileged {
+		t.Errorf("Expected AllowPrivileged to be %t while fn runs", !beforePrivileged)
+	}
+	if after := utilfeature.DefaultFeatureGate.Enabled(gate); after != before {
+		t.Errorf("Expected %s to be restored to %t, got %t", gate, before, after)
+	}
+	if after := capabilities.Get().AllowPrivileged; after != beforePrivileged {
+		t.Errorf("Expected AllowPrivileged to be restored to %t, got %t", beforePrivileged, after)
+	}
+	if err := withExampleConfig(exampleConfig{FeatureGates: []string{"NoSuchGate=true"}}, func() {}); err == nil {
+		t.Errorf("Expected an error for an unknown feature gate")
+	}
+}
+
+func TestFeatureGateSpec(t *testing.T) {
+	cases := []struct {
+		name             string
+		stage            string
+		enabledByDefault bool
+		found            bool
+	}{
+		{"PodShareProcessNamespace", "ALPHA", false, true},
+		{"CustomPodDNS", "BETA", true, true},
+		{"NoSuchGate", "", false, false},
+	}
+	for _, c := range cases {
+		stage, enabledByDefault, found := featureGateSpec(c.name)
+		if stage != c.stage || enabledByDefault != c.enabledByDefault || found != c.found {
+			t.Errorf("%s: expected (%q, %t, %t), got (%q, %t, %t)", c.name, c.stage, c.enabledByDefault, c.found, stage, enabledByDefault, found)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package examples
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// GlossaryTerm is a term of the glossary, a file of _data/glossary. See
+// _includes/templates/glossary/README.md for its fields.
+type GlossaryTerm struct {
+	Id               string   `yaml:"id"`
+	Name             string   `yaml:"name"`
+	FullLink         string   `yaml:"full-link"`
+	Aka              []string `yaml:"aka"`
+	Related          []string `yaml:"related"`
+	Tags             []string `yaml:"tags"`
+	ShortDescription string   `yaml:"short-description"`
+	LongDescription  string   `yaml:"long-description"`
+}
+
+// GlossaryFile is a glossary term and the path of its file.
+type GlossaryFile struct {
+	Path string
+	Term GlossaryTerm
+}
+
+// CanonicalTag is a tag that glossary terms may use, a file of
+// _data/canonical-tags.
+type CanonicalTag struct {
+	Id          string `yaml:"id"`
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+}
+
+// IsGlossaryFile returns true for the files of _data/glossary and
+// _data/canonical-tags that describe a term or a tag. Files starting with "_"
+// are templates.
+func IsGlossaryFile(name string) bool {
+	ext := path.Ext(name)
+	return !strings.HasPrefix(name, "_") && (ext == ".yml" || ext == ".yaml")
+}
+
+// GlossaryID returns the key of the glossary file at filePath in
+// site.data.glossary, the name of the file without its extension, by which
+// pages and related terms refer to the term.
+func GlossaryID(filePath string) string {
+	return strings.TrimSuffix(path.Base(filePath), path.Ext(filePath))
+}
+
+// loadGlossaryDir decodes each glossary file of dir with decode, in the order
+// of their names.
+func loadGlossaryDir(dir string, decode func(filePath string, data []byte) error) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if !IsGlossaryFile(f.Name()) {
+			continue
+		}
+		filePath := path.Join(dir, f.Name())
+		data, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		if err := decode(filePath, data); err != nil {
+			return fmt.Errorf("%s: %v", filePath, err)
+		}
+	}
+	return nil
+}
+
+// LoadGlossary returns the terms of the glossary in dir with their files, in
+// the order of the files. Pages and related terms refer to a term by the
+// GlossaryID of its file, not by its id.
+func LoadGlossary(dir string) ([]GlossaryFile, error) {
+	var files []GlossaryFile
+	err := loadGlossaryDir(dir, func(filePath string, data []byte) error {
+		f := GlossaryFile{Path: filePath}
+		if err := yaml.Unmarshal(data, &f.Term); err != nil {
+			return err
+		}
+		files = append(files, f)
+		return nil
+	})
+	return files, err
+}
+
+// LoadCanonicalTags returns the tags in dir, in the order of their files.
+func LoadCanonicalTags(dir string) ([]CanonicalTag, error) {
+	var tags []CanonicalTag
+	err := loadGlossaryDir(dir, func(filePath string, data []byte) error {
+		var tag CanonicalTag
+		if err := yaml.Unmarshal(data, &tag); err != nil {
+			return err
+		}
+		tags = append(tags, tag)
+		return nil
+	})
+	return tags, err
+}
@@ -17,90 +17,422 @@ limitations under the License.
 package examples_test
 
 import (
-  "io/ioutil"
-  "gopkg.in/yaml.v2"
-  "path"
-  "strings"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+
+	"gopkg.in/yaml.v2"
+
+	examples "k8s.io/website/test"
 )
 
-// Not unmarshaling short-description and long-description fields
-// (for simplicity)
-type GlossaryTerm struct {
-  Id string          `yaml: "id"`
-  Name string        `yaml: "name"`
-  Aka []string       `yaml: "aka"`
-  Related []string   `yaml: "related"`
-  Tags []string      `yaml: "tags"`
+const (
+	// glossaryDir holds one file per glossary term, named after its id.
+	// Files starting with "_" are templates.
+	glossaryDir = "../_data/glossary"
+	// canonicalTagsDir holds the tags that glossary terms may use.
+	canonicalTagsDir = "../_data/canonical-tags"
+	// glossaryStyleGuide describes the fields of glossary terms.
+	glossaryStyleGuide = "_includes/templates/glossary/README.md"
+)
+
+// glossaryFile is a glossary term and the position of its keys in its file.
+type glossaryFile struct {
+	Path string
+	Term examples.GlossaryTerm
+	// Keys maps the keys of the file to their lines.
+	Keys map[string]int
 }
 
-// Not unmarshaling other fields (for simplicity)
-type CanonicalTag struct {
-  Id string          `yaml: "id"`
+// finding returns a finding for the key of the file, at its line if the key
+// is present.
+func (g *glossaryFile) finding(key string) finding {
+	return finding{File: g.Path, Field: key, Line: g.Keys[key]}
+}
+
+// loadGlossaryFile parses the glossary file at filePath.
+func loadGlossaryFile(filePath string) (*glossaryFile, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	g := &glossaryFile{Path: filePath, Keys: map[string]int{}}
+	if err := yaml.Unmarshal(data, &g.Term); err != nil {
+		return nil, err
+	}
+	docs := parseSourceDocuments(data, 0, 0)
+	if len(docs) != 1 || docs[0].root.Kind != mappingNode {
+		return nil, fmt.Errorf("expected a mapping of the fields of the term")
+	}
+	root := docs[0].root
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		g.Keys[root.Content[i].Value] = root.Content[i].Line
+	}
+	return g, nil
+}
+
+// loadGlossaryFiles parses the glossary files of dir. Files that cannot be
+// parsed are reported to t.
+func loadGlossaryFiles(t *testing.T, dir string) ([]*glossaryFile, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var glossary []*glossaryFile
+	for _, f := range files {
+		if !examples.IsGlossaryFile(f.Name()) {
+			continue
+		}
+		filePath := path.Join(dir, f.Name())
+		g, err := loadGlossaryFile(filePath)
+		if err != nil {
+			reportError(t, finding{File: filePath}, "unable to unmarshal file: %v", err)
+			continue
+		}
+		glossary = append(glossary, g)
+	}
+	return glossary, nil
+}
+
+// glossaryKeys are the keys of a glossary file, from the yaml tags of
+// examples.GlossaryTerm.
+func glossaryKeys() map[string]bool {
+	keys := map[string]bool{}
+	termType := reflect.TypeOf(examples.GlossaryTerm{})
+	for i := 0; i < termType.NumField(); i++ {
+		keys[termType.Field(i).Tag.Get("yaml")] = true
+	}
+	return keys
+}
+
+// glossaryProblem is a violation of glossaryStyleGuide by a key of a
+// glossary file.
+type glossaryProblem struct {
+	Key     string
+	Message string
+}
+
+// repeatsTerm returns true if description starts with name, possibly after
+// an article, e.g. "A node is a worker machine" for the term Node.
+func repeatsTerm(description, name string) bool {
+	words := strings.Fields(strings.ToLower(description))
+	if len(words) > 0 && (words[0] == "a" || words[0] == "an" || words[0] == "the") {
+		words = words[1:]
+	}
+	nameWords := strings.Fields(strings.ToLower(name))
+	if len(nameWords) == 0 || len(words) < len(nameWords) {
+		return false
+	}
+	for i, word := range nameWords {
+		if strings.TrimRight(words[i], ".,:;") != word {
+			return false
+		}
+	}
+	return true
+}
+
+// glossaryProblems returns the violations of glossaryStyleGuide by g, except
+// for its tags, which are checked by TestCanonicalTags, and for its related
+// terms.
+func glossaryProblems(g *glossaryFile) []glossaryProblem {
+	var problems []glossaryProblem
+	add := func(key, format string, args ...interface{}) {
+		problems = append(problems, glossaryProblem{key, fmt.Sprintf(format, args...)})
+	}
+	known := glossaryKeys()
+	var keys []string
+	for key := range g.Keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !known[key] {
+			add(key, "unknown key %q", key)
+		}
+	}
+	term := g.Term
+	id := examples.GlossaryID(g.Path)
+	switch {
+	case term.Id == "":
+		add("id", "id is required")
+	case term.Id != id:
+		add("id", "id %q must match the name of the file, %q", term.Id, id)
+	}
+	if strings.TrimSpace(term.Name) == "" {
+		add("name", "name is required")
+	}
+	// full-link is optional in glossaryStyleGuide, many terms have none.
+	if term.FullLink != "" && !strings.HasPrefix(term.FullLink, "https://") && !strings.HasPrefix(term.FullLink, "/docs/") {
+		add("full-link", "full-link %q must start with https:// or, within the website, with /docs/", term.FullLink)
+	}
+	short := strings.TrimSpace(term.ShortDescription)
+	switch {
+	case short == "":
+		add("short-description", "short-description is required")
+	case !strings.HasSuffix(short, "."):
+		add("short-description", "short-description must end with a period")
+	case repeatsTerm(short, term.Name):
+		add("short-description", "short-description must not repeat the term %q", term.Name)
+	}
+	long := strings.TrimSpace(term.LongDescription)
+	switch {
+	case g.Keys["long-description"] > 0 && long == "":
+		add("long-description", "long-description is empty, remove the key instead")
+	case long != "" && !strings.HasSuffix(long, "."):
+		add("long-description", "long-description must be complete sentences ending with a period")
+	}
+	lists := []struct {
+		key    string
+		values []string
+	}{{"aka", term.Aka}, {"related", term.Related}, {"tags", term.Tags}}
+	for _, list := range lists {
+		for _, value := range list.values {
+			if strings.TrimSpace(value) == "" {
+				add(list.key, "%s must not contain empty values", list.key)
+			}
+		}
+	}
+	return problems
+}
+
+// TestGlossaryTerms checks that every glossary file follows
+// glossaryStyleGuide, in a subtest per file.
+func TestGlossaryTerms(t *testing.T) {
+	glossary, err := loadGlossaryFiles(t, glossaryDir)
+	if err != nil {
+		t.Fatalf("Unable to read directory %s: %v", glossaryDir, err)
+	}
+	if len(glossary) == 0 {
+		t.Fatalf("No glossary terms found in %s", glossaryDir)
+	}
+	for _, g := range glossary {
+		g := g
+		t.Run(subtestName(g.Path), func(t *testing.T) {
+			for _, p := range glossaryProblems(g) {
+				reportError(t, g.finding(p.Key), "%s, see %s", p.Message, glossaryStyleGuide)
+			}
+		})
+	}
+}
+
+func TestGlossaryProblems(t *testing.T) {
+	g := &glossaryFile{
+		Path: "../_data/glossary/node.yaml",
+		Term: examples.GlossaryTerm{
+			Id:               "Node",
+			Name:             "Node",
+			FullLink:         "docs/concepts/architecture/nodes/",
+			ShortDescription: "A node is a worker machine in Kubernetes.\n",
+			LongDescription:  "A worker machine may be a VM or physical machine\n",
+		},
+		Keys: map[string]int{"id": 1, "name": 2, "full-link": 3, "short-description": 4, "long-description": 6, "see-also": 8},
+	}
+	var actual []string
+	for _, p := range glossaryProblems(g) {
+		actual = append(actual, p.Key)
+	}
+	expected := []string{"see-also", "id", "full-link", "short-description", "long-description"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected problems with %v, got %v", expected, actual)
+	}
+
+	for _, c := range []struct {
+		description, name string
+		expected          bool
+	}{
+		{"A node is a worker machine in Kubernetes.", "Node", true},
+		{"Docker is a software technology.", "docker", true},
+		{"A worker machine in Kubernetes.", "Node", false},
+		{"Controllers that provide declarative updates for Pods.", "Deployment", false},
+		{"Kube-proxy runs on each node.", "kube-proxy", true},
+	} {
+		if actual := repeatsTerm(c.description, c.name); actual != c.expected {
+			t.Errorf("Expected repeatsTerm(%q, %q) to be %t", c.description, c.name, c.expected)
+		}
+	}
+}
+
+// glossaryRelationProblems returns the problems of the relations between the
+// terms of glossary, by glossary file: ids used by several files, e.g. by
+// foo.yml and foo.yaml, related terms that do not exist and aliases that are
+// the name or an alias of another term.
+func glossaryRelationProblems(glossary []*glossaryFile) map[*glossaryFile][]glossaryProblem {
+	problems := map[*glossaryFile][]glossaryProblem{}
+	add := func(g *glossaryFile, key, format string, args ...interface{}) {
+		problems[g] = append(problems[g], glossaryProblem{key, fmt.Sprintf(format, args...)})
+	}
+	ids := func(g *glossaryFile) []string {
+		stem := examples.GlossaryID(g.Path)
+		if g.Term.Id == "" || g.Term.Id == stem {
+			return []string{stem}
+		}
+		return []string{stem, g.Term.Id}
+	}
+	byID := map[string][]*glossaryFile{}
+	// byName maps the lower case names and aliases of the terms to their
+	// files.
+	byName := map[string][]*glossaryFile{}
+	for _, g := range glossary {
+		for _, id := range ids(g) {
+			byID[id] = append(byID[id], g)
+		}
+		byName[strings.ToLower(g.Term.Name)] = append(byName[strings.ToLower(g.Term.Name)], g)
+		for _, aka := range g.Term.Aka {
+			byName[strings.ToLower(aka)] = append(byName[strings.ToLower(aka)], g)
+		}
+	}
+	for _, g := range glossary {
+		stem := examples.GlossaryID(g.Path)
+		for _, id := range ids(g) {
+			for _, other := range byID[id] {
+				if other != g {
+					add(g, "id", "id %q is also used by %s", id, other.Path)
+				}
+			}
+		}
+		for _, related := range g.Term.Related {
+			switch {
+			case related == stem:
+				add(g, "related", "related must not include the term itself")
+			case len(byID[related]) == 0:
+				add(g, "related", "related term %q does not exist, related terms are ids of files in %s", related, glossaryDir)
+			}
+		}
+		for _, aka := range g.Term.Aka {
+			for _, other := range byName[strings.ToLower(aka)] {
+				if other != g {
+					add(g, "aka", "aka %q is also the name or an alias of %q", aka, examples.GlossaryID(other.Path))
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// missingRelations returns the symmetric closure of the related terms of
+// glossary: for each term, by id, the sorted ids of the terms that relate to
+// it but that it does not relate to.
+func missingRelations(glossary []*glossaryFile) map[string][]string {
+	related := map[string]map[string]bool{}
+	for _, g := range glossary {
+		related[examples.GlossaryID(g.Path)] = map[string]bool{}
+	}
+	for _, g := range glossary {
+		for _, id := range g.Term.Related {
+			related[examples.GlossaryID(g.Path)][id] = true
+		}
+	}
+	missing := map[string][]string{}
+	for id, terms := range related {
+		for term := range terms {
+			if reverse, found := related[term]; found && term != id && !reverse[id] {
+				missing[term] = append(missing[term], id)
+			}
+		}
+	}
+	for _, ids := range missing {
+		sort.Strings(ids)
+	}
+	return missing
+}
+
+// TestGlossaryRelations checks that the related terms of the glossary exist
+// and that ids and aliases are unique. Related terms that do not relate back
+// are warnings, which list the terms to add for symmetric relations.
+func TestGlossaryRelations(t *testing.T) {
+	glossary, err := loadGlossaryFiles(t, glossaryDir)
+	if err != nil {
+		t.Fatalf("Unable to read directory %s: %v", glossaryDir, err)
+	}
+	problems := glossaryRelationProblems(glossary)
+	missing := missingRelations(glossary)
+	for _, g := range glossary {
+		for _, p := range problems[g] {
+			reportError(t, g.finding(p.Key), "%s", p.Message)
+		}
+		if ids := missing[examples.GlossaryID(g.Path)]; len(ids) > 0 {
+			reportWarning(t, g.finding("related"), "related terms are not symmetric, add %s, which relate to this term", strings.Join(ids, ", "))
+		}
+	}
+}
+
+func TestGlossaryRelationProblems(t *testing.T) {
+	term := func(filePath, name string, aka []string, related ...string) *glossaryFile {
+		return &glossaryFile{
+			Path: filePath,
+			Term: examples.GlossaryTerm{Id: examples.GlossaryID(filePath), Name: name, Aka: aka, Related: related},
+			Keys: map[string]int{},
+		}
+	}
+	pod := term("pod.yaml", "Pod", nil, "container", "sidecar")
+	container := term("container.yaml", "Container", []string{"Pod"})
+	deployment := term("deployment.yml", "Deployment", nil, "pod", "container", "deployment")
+	duplicate := term("deployment.yaml", "Deployments", nil)
+	glossary := []*glossaryFile{pod, container, deployment, duplicate}
+
+	keys := func(g *glossaryFile) []string {
+		var keys []string
+		for _, p := range glossaryRelationProblems(glossary)[g] {
+			keys = append(keys, p.Key)
+		}
+		return keys
+	}
+	for _, c := range []struct {
+		g        *glossaryFile
+		expected []string
+	}{
+		{pod, []string{"related"}},
+		{container, []string{"aka"}},
+		{deployment, []string{"id", "related"}},
+		{duplicate, []string{"id"}},
+	} {
+		if actual := keys(c.g); !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("Expected problems of %s with %v, got %v", c.g.Path, c.expected, actual)
+		}
+	}
+
+	expected := map[string][]string{
+		"container": {"deployment", "pod"},
+		"pod":       {"deployment"},
+	}
+	if actual := missingRelations(glossary); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected missing relations %v, got %v", expected, actual)
+	}
 }
 
 // Checks that all glossary files (../_data/glossary/*) contain valid tags
 // that are present in the canonical set.
 func TestCanonicalTags(t *testing.T) {
-  canonicalTagsDir := "../_data/canonical-tags"
-  files, err := ioutil.ReadDir(canonicalTagsDir)
-  if err != nil {
-    t.Errorf("Unable to read directory %s: %v", canonicalTagsDir, err)
-    return
-  }
-
-  canonicalTagsSet := make(map[string]bool)
-  var tag CanonicalTag
-  for _, f := range files {
-    filePath := path.Join(canonicalTagsDir, f.Name())
-    data, err := ioutil.ReadFile(filePath)
-    if err != nil {
-      t.Errorf("Unable to read file %s: %v", filePath, err)
-      continue
-    }
-    err = yaml.Unmarshal(data, &tag)
-    if err != nil {
-      t.Errorf("Unable to unmarshal file %s: %v", filePath, err)
-      continue
-    }
-
-    canonicalTagsSet[tag.Id] = true
-  }
-
-  glossaryDir := "../_data/glossary"
-  files, err = ioutil.ReadDir(glossaryDir)
-  if err != nil {
-    t.Errorf("Unable to read directory %s: %v", glossaryDir, err)
-    return
-  }
-
-  for _, f := range files {
-    var term GlossaryTerm
-    // skip validation of example files
-    if (strings.HasPrefix(f.Name(), "_")) {
-      continue
-    }
-
-    filePath := path.Join(glossaryDir, f.Name())
-    data, err := ioutil.ReadFile(filePath)
-    if err != nil {
-      t.Errorf("Unable to read file %s: %v", filePath, err)
-      continue
-    }
-    err = yaml.Unmarshal(data, &term)
-    if err != nil {
-      t.Errorf("Unable to unmarshal file %s: %v", filePath, err)
-      continue
-    }
-
-    if (len(term.Tags) == 0) {
-      t.Errorf("Glossary term \"%s\" requires at least one tag. See %s for the list of valid tags.", term.Name, canonicalTagsDir)
-    }
-    for _, tag := range term.Tags {
-      if _, present := canonicalTagsSet[tag]; !present {
-        t.Errorf("Glossary term \"%s\" has invalid tag \"%s\". See %s for the list of valid tags.", term.Name, tag, canonicalTagsDir)
-        continue
-      }
-    }
-  }
+	tags, err := examples.LoadCanonicalTags(canonicalTagsDir)
+	if err != nil {
+		t.Errorf("Unable to read directory %s: %v", canonicalTagsDir, err)
+		return
+	}
+	canonicalTagsSet := make(map[string]bool)
+	for _, tag := range tags {
+		canonicalTagsSet[tag.Id] = true
+	}
+
+	glossary, err := loadGlossaryFiles(t, glossaryDir)
+	if err != nil {
+		t.Errorf("Unable to read directory %s: %v", glossaryDir, err)
+		return
+	}
+
+	for _, g := range glossary {
+		term := g.Term
+		if len(term.Tags) == 0 {
+			reportError(t, g.finding("tags"), "glossary term \"%s\" requires at least one tag. See %s for the list of valid tags.", term.Name, canonicalTagsDir)
+		}
+		for _, tag := range term.Tags {
+			if _, present := canonicalTagsSet[tag]; !present {
+				reportError(t, g.finding("tags"), "glossary term \"%s\" has invalid tag \"%s\". See %s for the list of valid tags.", term.Name, tag, canonicalTagsDir)
+				continue
+			}
+		}
+	}
 }
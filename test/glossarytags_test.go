@@ -0,0 +1,209 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package examples_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	examples "k8s.io/website/test"
+)
+
+// glossaryTagParameters are the parameters of the glossary tags of
+// _plugins/glossary_tags.rb, by tag. The plugin ignores other parameters.
+var glossaryTagParameters = map[string][]string{
+	"glossary_definition": {"term_id", "length", "prepend"},
+	"glossary_tooltip":    {"text", "term_id"},
+	"glossary_injector":   {"text", "term_id", "placeholder_id", "length"},
+}
+
+// glossaryTagLengths are the values of the length parameter, see
+// _includes/templates/glossary/snippet.md.
+var glossaryTagLengths = []string{"short", "long", "all"}
+
+var (
+	glossaryTagRegexp          = regexp.MustCompile(`\{%-?\s*(glossary_\w+)\s([^%]*)-?%\}`)
+	glossaryTagParameterRegexp = regexp.MustCompile(`(\w+)=(?:"([^"]*)"|'([^']*)')`)
+)
+
+// glossaryTag is a glossary tag in a page or a glossary term, e.g.
+// {% glossary_tooltip text="Pods" term_id="pod" %}.
+type glossaryTag struct {
+	// Line is the 1-based line number of the tag.
+	Line   int
+	Name   string
+	Params map[string]string
+}
+
+// extractGlossaryTags returns the glossary tags of text, in order. Tags in a
+// Liquid string, e.g. {{ "{% glossary_tooltip" }}, are shown on the page
+// rather than rendered and are skipped.
+func extractGlossaryTags(text string) []glossaryTag {
+	var tags []glossaryTag
+	for i, line := range strings.Split(text, "\n") {
+		for _, match := range glossaryTagRegexp.FindAllStringSubmatchIndex(line, -1) {
+			if strings.HasSuffix(line[:match[0]], `{{ "`) {
+				continue
+			}
+			tag := glossaryTag{Line: i + 1, Name: line[match[2]:match[3]], Params: map[string]string{}}
+			for _, param := range glossaryTagParameterRegexp.FindAllStringSubmatch(line[match[4]:match[5]], -1) {
+				tag.Params[param[1]] = param[2] + param[3]
+			}
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// glossaryTagProblems returns the problems of tag, given the ids of the
+// glossary terms, which are the names of their files.
+func glossaryTagProblems(tag glossaryTag, terms map[string]bool) []string {
+	valid, found := glossaryTagParameters[tag.Name]
+	if !found {
+		return []string{fmt.Sprintf("unknown tag %s", tag.Name)}
+	}
+	var problems []string
+	var names []string
+	for name := range tag.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !containsString(valid, name) {
+			problems = append(problems, fmt.Sprintf("unknown parameter %s of %s, expected one of %s", name, tag.Name, strings.Join(valid, ", ")))
+		}
+	}
+	switch id, found := tag.Params["term_id"]; {
+	case !found:
+		problems = append(problems, fmt.Sprintf("%s requires a term_id", tag.Name))
+	case !terms[id]:
+		problems = append(problems, fmt.Sprintf("%q is not a glossary term id, see %s", id, glossaryDir))
+	}
+	if length, found := tag.Params["length"]; found && containsString(valid, "length") && !containsString(glossaryTagLengths, length) {
+		problems = append(problems, fmt.Sprintf("invalid length %q, expected one of %s", length, strings.Join(glossaryTagLengths, ", ")))
+	}
+	if _, found := tag.Params["placeholder_id"]; !found && containsString(valid, "placeholder_id") {
+		problems = append(problems, fmt.Sprintf("%s requires a placeholder_id", tag.Name))
+	}
+	return problems
+}
+
+// containsString returns true if list contains s.
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// TestGlossaryTags checks the glossary tags of the Markdown pages and of the
+// descriptions of the glossary terms. An unknown term id only fails when
+// Jekyll renders the page, the other problems are ignored by Jekyll.
+func TestGlossaryTags(t *testing.T) {
+	glossary, err := loadGlossaryFiles(t, glossaryDir)
+	if err != nil {
+		t.Fatalf("Unable to read directory %s: %v", glossaryDir, err)
+	}
+	terms := map[string]bool{}
+	for _, g := range glossary {
+		terms[examples.GlossaryID(g.Path)] = true
+	}
+
+	for _, root := range markdownRoots {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || filepath.Ext(path) != ".md" {
+				return nil
+			}
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				reportError(t, finding{File: path}, "unable to read file: %v", err)
+				return nil
+			}
+			for _, tag := range extractGlossaryTags(string(data)) {
+				for _, problem := range glossaryTagProblems(tag, terms) {
+					reportError(t, finding{File: path, Line: tag.Line}, "%s", problem)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Errorf("Expected no error, Got %v on Path %v", err, root)
+		}
+	}
+
+	for _, g := range glossary {
+		descriptions := []struct {
+			key, text string
+		}{{"short-description", g.Term.ShortDescription}, {"long-description", g.Term.LongDescription}}
+		for _, description := range descriptions {
+			for _, tag := range extractGlossaryTags(description.text) {
+				for _, problem := range glossaryTagProblems(tag, terms) {
+					reportError(t, g.finding(description.key), "%s", problem)
+				}
+			}
+		}
+	}
+}
+
+func TestExtractGlossaryTags(t *testing.T) {
+	markdown := `A {% glossary_tooltip text="Pod" term_id="pod" %} runs
+{% glossary_definition term_id='kubectl' length="all" prepend="kubectl is"%}
+{{ "{% glossary_tooltip text=" }}"cluster" term_id="cluster" %}
+`
+	expected := []glossaryTag{
+		{Line: 1, Name: "glossary_tooltip", Params: map[string]string{"text": "Pod", "term_id": "pod"}},
+		{Line: 2, Name: "glossary_definition", Params: map[string]string{"term_id": "kubectl", "length": "all", "prepend": "kubectl is"}},
+	}
+	if actual := extractGlossaryTags(markdown); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, actual)
+	}
+
+	terms := map[string]bool{"pod": true}
+	for _, c := range []struct {
+		tag      string
+		problems int
+	}{
+		{`{% glossary_tooltip term_id="pod" %}`, 0},
+		{`{% glossary_tooltip text="Pods" term_id="pods" %}`, 1},
+		{`{% glossary_tooltip term_id="pod" length="all" %}`, 1},
+		{`{% glossary_definition term_id="pod" length="full" %}`, 1},
+		{`{% glossary_definition length="short" %}`, 1},
+		{`{% glossary_injector term_id="pod" length="long" %}`, 1},
+		{`{% glossary_injector term_id="pod" placeholder_id="def" %}`, 0},
+	} {
+		tags := extractGlossaryTags(c.tag)
+		if len(tags) != 1 {
+			t.Errorf("Expected one tag in %s, got %d", c.tag, len(tags))
+			continue
+		}
+		if problems := glossaryTagProblems(tags[0], terms); len(problems) != c.problems {
+			t.Errorf("Expected %d problems with %s, got %v", c.problems, c.tag, problems)
+		}
+	}
+}
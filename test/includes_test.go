@@ -0,0 +1,194 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package examples_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// siteRoot is the root of the website repository.
+const siteRoot = ".."
+
+// translationPrefixes are the directories, relative to siteRoot, of the
+// translated documentation. Translated pages may link to the examples of the
+// original page instead of their own copy.
+var translationPrefixes = []string{"cn/"}
+
+// codeInclude is a {% include code.html ... %} tag in a Markdown page.
+type codeInclude struct {
+	// Line is the 1-based line number of the tag.
+	Line int
+	// File is the included example, relative to the page.
+	File string
+	// GHLink is the path of the example in the repository, starting with a
+	// slash, used for the download link. It may be empty.
+	GHLink string
+}
+
+var (
+	codeIncludeTagRegexp   = regexp.MustCompile(`\{%\s*include\s+code\.html\s([^%]*)%\}`)
+	includeParameterRegexp = regexp.MustCompile(`(\w+)="([^"]*)"`)
+)
+
+// extractCodeIncludes returns the code.html includes of the Markdown page in
+// data, in order.
+func extractCodeIncludes(data []byte) []codeInclude {
+	var includes []codeInclude
+	for i, line := range strings.Split(string(data), "\n") {
+		for _, tag := range codeIncludeTagRegexp.FindAllStringSubmatch(line, -1) {
+			include := codeInclude{Line: i + 1}
+			for _, param := range includeParameterRegexp.FindAllStringSubmatch(tag[1], -1) {
+				switch param[1] {
+				case "file":
+					include.File = param[2]
+				case "ghlink":
+					include.GHLink = param[2]
+				}
+			}
+			includes = append(includes, include)
+		}
+	}
+	return includes
+}
+
+// repositoryPath returns path, a file below siteRoot, relative to siteRoot
+// with slashes, e.g. docs/tasks/run-application/deployment.yaml.
+func repositoryPath(path string) (string, error) {
+	rel, err := filepath.Rel(siteRoot, path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// ghlinkMatches returns true if ghlink is the path of the example rel, or of
+// the original of a translated example.
+func ghlinkMatches(ghlink, rel string) bool {
+	if ghlink == "/"+rel {
+		return true
+	}
+	for _, prefix := range translationPrefixes {
+		if strings.HasPrefix(rel, prefix) && ghlink == "/"+strings.TrimPrefix(rel, prefix) {
+			_, err := os.Stat(filepath.Join(siteRoot, filepath.FromSlash(ghlink)))
+			return err == nil
+		}
+	}
+	return false
+}
+
+// TestCodeIncludes verifies that every example included in a Markdown page
+// with code.html exists relative to the page and that its ghlink points to
+// the same file. It also reports the examples that no page uses, either with
+// code.html or with a link to the file, e.g. in a kubectl command.
+func TestCodeIncludes(t *testing.T) {
+	used := map[string]bool{}
+	var pages []string
+	for _, root := range markdownRoots {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || filepath.Ext(path) != ".md" {
+				return nil
+			}
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				reportError(t, finding{File: path}, "unable to read file: %v", err)
+				return nil
+			}
+			pages = append(pages, string(data))
+			for _, include := range extractCodeIncludes(data) {
+				f := finding{File: path, Line: include.Line}
+				if include.File == "" {
+					reportError(t, f, "code.html include has no file parameter")
+					continue
+				}
+				example := filepath.Join(filepath.Dir(path), filepath.FromSlash(include.File))
+				if _, err := os.Stat(example); err != nil {
+					reportError(t, f, "included file %s does not exist", include.File)
+					continue
+				}
+				rel, err := repositoryPath(example)
+				if err != nil {
+					reportError(t, f, "%v", err)
+					continue
+				}
+				used[rel] = true
+				if include.GHLink != "" && !ghlinkMatches(include.GHLink, rel) {
+					reportError(t, f, "ghlink %s does not match the included file, expected /%s", include.GHLink, rel)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Errorf("Expected no error, Got %v on Path %v", err, root)
+		}
+	}
+
+	var orphans []string
+	for _, root := range markdownRoots {
+		err := walkConfigFiles(root, func(name, path string, docs [][]byte, config *directoryConfig) {
+			rel, err := repositoryPath(path)
+			if err != nil {
+				reportError(t, finding{File: path}, "%v", err)
+				return
+			}
+			if !used[rel] {
+				orphans = append(orphans, rel)
+			}
+		})
+		if err != nil {
+			t.Errorf("Expected no error, Got %v on Path %v", err, root)
+		}
+	}
+	// Examples are also used with links, e.g. in
+	// kubectl apply -f https://k8s.io/docs/tasks/run-application/deployment.yaml
+	for _, rel := range orphans {
+		linked := false
+		for _, page := range pages {
+			if strings.Contains(page, rel) {
+				linked = true
+				break
+			}
+		}
+		if !linked {
+			reportWarning(t, finding{File: filepath.Join(siteRoot, filepath.FromSlash(rel))}, "example is not used by any page")
+		}
+	}
+}
+
+func TestExtractCodeIncludes(t *testing.T) {
+	markdown := `Create the deployment:
+
+{% include code.html language="yaml" file="deployment.yaml" ghlink="/docs/tasks/deployment.yaml" %}
+
+{%include code.html file="configmap/game.properties" %} and text
+`
+	expected := []codeInclude{
+		{Line: 3, File: "deployment.yaml", GHLink: "/docs/tasks/deployment.yaml"},
+		{Line: 5, File: "configmap/game.properties"},
+	}
+	if actual := extractCodeIncludes([]byte(markdown)); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, actual)
+	}
+}
@@ -0,0 +1,311 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package examples_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/kubernetes/pkg/apis/apps"
+	"k8s.io/kubernetes/pkg/apis/batch"
+	api "k8s.io/kubernetes/pkg/apis/core"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+)
+
+// lintRule checks a decoded example for a practice that the documentation
+// should not model, even though the API server accepts it. Problems are
+// reported as warnings, not as validation errors.
+type lintRule struct {
+	// Name is used to select or suppress the rule in directoryConfigFile.
+	Name string
+	// Check returns one warning per problem found in obj.
+	Check func(obj runtime.Object) []lintWarning
+}
+
+// lintWarning is a problem found by a lintRule.
+type lintWarning struct {
+	Field   *field.Path
+	Message string
+}
+
+// lintRules are all the rules, in the order they are run. By default every
+// rule runs on every example.
+var lintRules = []lintRule{
+	{Name: "latest-image-tag", Check: checkImageTags},
+	{Name: "missing-resources", Check: checkResources},
+	{Name: "privileged", Check: checkPrivileged},
+	{Name: "missing-probes", Check: checkProbes},
+}
+
+// findLintRule returns the rule called name.
+func findLintRule(name string) (lintRule, bool) {
+	for _, rule := range lintRules {
+		if rule.Name == name {
+			return rule, true
+		}
+	}
+	return lintRule{}, false
+}
+
+// podSpecRef is a pod spec found in an object.
+type podSpecRef struct {
+	Spec *api.PodSpec
+	Path *field.Path
+	// Labels are the labels of the pod or of the pod template.
+	Labels map[string]string
+	// LongRunning is true for the pods of controllers that keep them
+	// running, as opposed to bare pods and jobs.
+	LongRunning bool
+}
+
+// podSpecs returns the pod specs of obj, either its own or the ones of its
+// pod templates.
+func podSpecs(obj runtime.Object) []podSpecRef {
+	template := field.NewPath("spec", "template", "spec")
+	switch t := obj.(type) {
+	case *api.Pod:
+		return []podSpecRef{{&t.Spec, field.NewPath("spec"), t.Labels, false}}
+	case *api.PodList:
+		var refs []podSpecRef
+		for i := range t.Items {
+			refs = append(refs, podSpecRef{&t.Items[i].Spec, field.NewPath("items").Index(i).Child("spec"), t.Items[i].Labels, false})
+		}
+		return refs
+	case *api.PodTemplate:
+		return []podSpecRef{{&t.Template.Spec, field.NewPath("template", "spec"), t.Template.Labels, false}}
+	case *api.ReplicationController:
+		if t.Spec.Template != nil {
+			return []podSpecRef{{&t.Spec.Template.Spec, template, t.Spec.Template.Labels, true}}
+		}
+	case *apps.StatefulSet:
+		return []podSpecRef{{&t.Spec.Template.Spec, template, t.Spec.Template.Labels, true}}
+	case *extensions.DaemonSet:
+		return []podSpecRef{{&t.Spec.Template.Spec, template, t.Spec.Template.Labels, true}}
+	case *extensions.Deployment:
+		return []podSpecRef{{&t.Spec.Template.Spec, template, t.Spec.Template.Labels, true}}
+	case *extensions.ReplicaSet:
+		return []podSpecRef{{&t.Spec.Template.Spec, template, t.Spec.Template.Labels, true}}
+	case *batch.Job:
+		return []podSpecRef{{&t.Spec.Template.Spec, template, t.Spec.Template.Labels, false}}
+	case *batch.CronJob:
+		return []podSpecRef{{&t.Spec.JobTemplate.Spec.Template.Spec, field.NewPath("spec", "jobTemplate", "spec", "template", "spec"), t.Spec.JobTemplate.Spec.Template.Labels, false}}
+	}
+	return nil
+}
+
+// imageTag returns the tag of image, or an empty string if it has none, and
+// whether the image is pinned by digest instead.
+func imageTag(image string) (tag string, pinned bool) {
+	if strings.Contains(image, "@") {
+		return "", true
+	}
+	name := image[strings.LastIndex(image, "/")+1:]
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[i+1:], false
+	}
+	return "", false
+}
+
+func checkImageTags(obj runtime.Object) []lintWarning {
+	var warnings []lintWarning
+	check := func(containers []api.Container, path *field.Path) {
+		for i, c := range containers {
+			tag, pinned := imageTag(c.Image)
+			switch {
+			case pinned:
+			case tag == "":
+				warnings = append(warnings, lintWarning{path.Index(i).Child("image"), fmt.Sprintf("image %q has no tag and uses latest, use a fixed version", c.Image)})
+			case tag == "latest":
+				warnings = append(warnings, lintWarning{path.Index(i).Child("image"), fmt.Sprintf("image %q uses the latest tag, use a fixed version", c.Image)})
+			}
+		}
+	}
+	for _, ref := range podSpecs(obj) {
+		check(ref.Spec.InitContainers, ref.Path.Child("initContainers"))
+		check(ref.Spec.Containers, ref.Path.Child("containers"))
+	}
+	return warnings
+}
+
+func checkResources(obj runtime.Object) []lintWarning {
+	var warnings []lintWarning
+	for _, ref := range podSpecs(obj) {
+		for i, c := range ref.Spec.Containers {
+			if len(c.Resources.Requests) == 0 && len(c.Resources.Limits) == 0 {
+				warnings = append(warnings, lintWarning{ref.Path.Child("containers").Index(i).Child("resources"), fmt.Sprintf("container %q has no resource requests or limits", c.Name)})
+			}
+		}
+	}
+	return warnings
+}
+
+func checkPrivileged(obj runtime.Object) []lintWarning {
+	var warnings []lintWarning
+	check := func(containers []api.Container, path *field.Path) {
+		for i, c := range containers {
+			if c.SecurityContext != nil && c.SecurityContext.Privileged != nil && *c.SecurityContext.Privileged {
+				warnings = append(warnings, lintWarning{path.Index(i).Child("securityContext", "privileged"), fmt.Sprintf("container %q is privileged", c.Name)})
+			}
+		}
+	}
+	for _, ref := range podSpecs(obj) {
+		check(ref.Spec.InitContainers, ref.Path.Child("initContainers"))
+		check(ref.Spec.Containers, ref.Path.Child("containers"))
+	}
+	return warnings
+}
+
+func checkProbes(obj runtime.Object) []lintWarning {
+	var warnings []lintWarning
+	for _, ref := range podSpecs(obj) {
+		if !ref.LongRunning {
+			continue
+		}
+		for i, c := range ref.Spec.Containers {
+			if c.LivenessProbe == nil && c.ReadinessProbe == nil {
+				warnings = append(warnings, lintWarning{ref.Path.Child("containers").Index(i), fmt.Sprintf("container %q has no liveness or readiness probe", c.Name)})
+			}
+		}
+	}
+	return warnings
+}
+
+// enabledLintRules returns the rules selected for an example: the rules
+// listed for its directory, or all of them, minus the ones it suppresses.
+func enabledLintRules(config *directoryConfig, example exampleConfig) ([]lintRule, error) {
+	names := config.Lint
+	if names == nil {
+		for _, rule := range lintRules {
+			names = append(names, rule.Name)
+		}
+	}
+	var rules []lintRule
+	for _, name := range names {
+		rule, found := findLintRule(name)
+		if !found {
+			return nil, fmt.Errorf("unknown lint rule %q", name)
+		}
+		suppressed := false
+		for _, s := range example.SuppressLint {
+			if _, found := findLintRule(s); !found {
+				return nil, fmt.Errorf("unknown lint rule %q", s)
+			}
+			suppressed = suppressed || s == name
+		}
+		if !suppressed {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// TestLintExamples runs the lint rules on every example and logs the
+// problems as warnings (run with -v to see them).
+func TestLintExamples(t *testing.T) {
+	err := walkConfigFiles(examplesRoot, func(name, path string, docs [][]byte, config *directoryConfig) {
+		rules, err := enabledLintRules(config, config.example(filepath.Base(path)))
+		if err != nil {
+			reportError(t, finding{File: path}, "invalid lint rules in %s: %v", directoryConfigFile, err)
+			return
+		}
+		if len(rules) == 0 {
+			return
+		}
+		for i, data := range docs {
+			typeMeta, err := documentTypeMeta(data)
+			if err != nil {
+				continue
+			}
+			obj, err := decodeDocument(data)
+			if err != nil {
+				// Reported by TestExampleObjectSchemas.
+				continue
+			}
+			var src *sourceDocument
+			for _, rule := range rules {
+				for _, w := range rule.Check(obj) {
+					if src == nil {
+						src = fileSourceDocument(path, i)
+					}
+					f := finding{File: path, Document: i, Kind: typeMeta.Kind, Field: w.Field.String()}
+					if src != nil {
+						f.Line, f.Column = src.position(f.Field)
+					}
+					reportWarning(t, f, "%s: %s", rule.Name, w.Message)
+				}
+			}
+		}
+	})
+	if err != nil {
+		t.Errorf("Expected no error, Got %v on Path %v", err, examplesRoot)
+	}
+}
+
+func TestLintRules(t *testing.T) {
+	privileged := true
+	probe := &api.Probe{}
+	pod := func(containers ...api.Container) *api.Pod {
+		return &api.Pod{Spec: api.PodSpec{Containers: containers}}
+	}
+	deployment := func(containers ...api.Container) *extensions.Deployment {
+		d := &extensions.Deployment{}
+		d.Spec.Template.Spec.Containers = containers
+		return d
+	}
+	resources := api.ResourceRequirements{Requests: api.ResourceList{api.ResourceCPU: {}}}
+	good := api.Container{Name: "c", Image: "nginx:1.7.9", Resources: resources, LivenessProbe: probe}
+
+	cases := []struct {
+		rule     string
+		obj      runtime.Object
+		expected []string
+	}{
+		{"latest-image-tag", pod(good), nil},
+		{"latest-image-tag", pod(api.Container{Image: "nginx"}), []string{"spec.containers[0].image"}},
+		{"latest-image-tag", pod(api.Container{Image: "localhost:5000/nginx:latest"}), []string{"spec.containers[0].image"}},
+		{"latest-image-tag", pod(api.Container{Image: "localhost:5000/nginx"}), []string{"spec.containers[0].image"}},
+		{"latest-image-tag", pod(api.Container{Image: "nginx@sha256:0000"}), nil},
+		{"latest-image-tag", deployment(good, api.Container{Image: "busybox"}), []string{"spec.template.spec.containers[1].image"}},
+		{"missing-resources", pod(good), nil},
+		{"missing-resources", pod(api.Container{Name: "c"}), []string{"spec.containers[0].resources"}},
+		{"privileged", pod(good), nil},
+		{"privileged", pod(api.Container{SecurityContext: &api.SecurityContext{Privileged: &privileged}}), []string{"spec.containers[0].securityContext.privileged"}},
+		{"missing-probes", pod(api.Container{}), nil},
+		{"missing-probes", deployment(good), nil},
+		{"missing-probes", deployment(api.Container{ReadinessProbe: probe}), nil},
+		{"missing-probes", deployment(api.Container{}), []string{"spec.template.spec.containers[0]"}},
+		{"missing-probes", &api.Service{}, nil},
+	}
+	for _, c := range cases {
+		rule, found := findLintRule(c.rule)
+		if !found {
+			t.Fatalf("Unknown rule %s", c.rule)
+		}
+		var actual []string
+		for _, w := range rule.Check(c.obj) {
+			actual = append(actual, w.Field.String())
+		}
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%s on %#v: expected warnings for %v, got %v", c.rule, c.obj, c.expected, actual)
+		}
+	}
+}
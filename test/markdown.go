@@ -0,0 +1,87 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package examples
+
+import "strings"
+
+// CodeBlock is a fenced code block of a Markdown page.
+type CodeBlock struct {
+	// Lang is the lower-cased first word of the info string, e.g. "yaml".
+	Lang string
+	// Line is the 1-based line number of the opening fence.
+	Line int
+	// Indent is the number of spaces or tabs before the opening fence.
+	Indent int
+	// Content is the text between the fences, with the indentation of the
+	// opening fence removed from every line.
+	Content string
+	// Markers are the comments on the lines before the opening fence, blank
+	// lines aside, e.g. <!-- skip-validation: fragment -->.
+	Markers []string
+}
+
+// ExtractCodeBlocks returns the fenced code blocks of the Markdown document in
+// data, in order. Fences may be indented, e.g. inside list items. Blocks that
+// are not closed are dropped.
+func ExtractCodeBlocks(data []byte) []CodeBlock {
+	var (
+		blocks  []CodeBlock
+		current *CodeBlock
+		content []string
+		markers []string
+	)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		trimmed := strings.TrimLeft(line, " \t")
+		if current == nil {
+			if strings.HasPrefix(trimmed, "```") {
+				lang := ""
+				if fields := strings.Fields(strings.TrimPrefix(trimmed, "```")); len(fields) > 0 {
+					lang = strings.ToLower(fields[0])
+				}
+				current = &CodeBlock{Lang: lang, Line: i + 1, Indent: len(line) - len(trimmed), Markers: markers}
+				content = nil
+				markers = nil
+				continue
+			}
+			switch marker := strings.TrimSpace(line); {
+			case strings.HasPrefix(marker, "<!--"):
+				markers = append(markers, marker)
+			case marker != "":
+				markers = nil
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") && strings.TrimSpace(strings.TrimPrefix(trimmed, "```")) == "" {
+			current.Content = strings.Join(content, "\n")
+			blocks = append(blocks, *current)
+			current = nil
+			continue
+		}
+		content = append(content, trimIndent(line, current.Indent))
+	}
+	return blocks
+}
+
+// trimIndent removes up to n leading spaces or tabs from line.
+func trimIndent(line string, n int) string {
+	i := 0
+	for i < n && i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+		i++
+	}
+	return line[i:]
+}
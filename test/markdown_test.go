@@ -0,0 +1,220 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package examples_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	examples "k8s.io/website/test"
+)
+
+// skipValidationMarkerPrefix starts a comment on the line before a fenced
+// code block that excludes it from TestReadme, with the reason, e.g.
+// <!-- skip-validation: placeholder values -->. The reason is required, and
+// the other markers are preferred when they apply.
+const skipValidationMarkerPrefix = "<!-- skip-validation:"
+
+// lenientDecodingMarker can be put on the line before a fenced code block to
+// validate it without strict decoding, e.g. for a snippet that shows how a
+// misspelled field is ignored.
+const lenientDecodingMarker = "<!-- lenient-decoding -->"
+
+// featureGatesMarkerPrefix starts a comment on the line before a fenced code
+// block that lists the feature gates enabled while validating the block,
+// e.g. <!-- feature-gates: CustomResourceSubresources=true -->.
+const featureGatesMarkerPrefix = "<!-- feature-gates:"
+
+// expectInvalidMarkerPrefix starts a comment on the line before a fenced code
+// block whose objects the API server rejects, with a fragment of the error
+// message, e.g. <!-- expect-invalid: should be less than or equal to 10 -->.
+const expectInvalidMarkerPrefix = "<!-- expect-invalid:"
+
+// markerValue returns the text of marker, a comment starting with prefix, or
+// false if marker does not start with prefix.
+func markerValue(marker, prefix string) (string, bool) {
+	if !strings.HasPrefix(marker, prefix) || !strings.HasSuffix(marker, "-->") {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(marker, prefix), "-->")), true
+}
+
+// featureGates returns the feature gates listed by marker, a
+// featureGatesMarkerPrefix comment, or nil.
+func featureGates(marker string) []string {
+	list, found := markerValue(marker, featureGatesMarkerPrefix)
+	if !found {
+		return nil
+	}
+	var gates []string
+	for _, gate := range strings.Split(list, ",") {
+		if gate = strings.TrimSpace(gate); gate != "" {
+			gates = append(gates, gate)
+		}
+	}
+	return gates
+}
+
+// codeBlock is a fenced code block found in a Markdown file.
+type codeBlock struct {
+	// Lang is the lower-cased first word of the info string, e.g. "yaml".
+	Lang string
+	// Line is the 1-based line number of the opening fence.
+	Line int
+	// Indent is the number of spaces or tabs before the opening fence.
+	Indent int
+	// Content is the text between the fences, with the indentation of the
+	// opening fence removed from every line.
+	Content string
+	// SkipReason is the reason of a skipValidationMarkerPrefix comment
+	// before the block, if any.
+	SkipReason string
+	// Lenient is true if the block is preceded by lenientDecodingMarker.
+	Lenient bool
+	// FeatureGates are listed by a featureGatesMarkerPrefix comment before
+	// the block.
+	FeatureGates []string
+	// ExpectInvalid is set by the expectInvalidMarkerPrefix comments before
+	// the block, one per error.
+	ExpectInvalid *expectedRejection
+	// InvalidMarkers are the markers before the block that are incomplete,
+	// e.g. a skip-validation comment without a reason.
+	InvalidMarkers []string
+}
+
+// applyMarkers sets the fields of b described by markers, the comments on
+// the lines before the block.
+func (b *codeBlock) applyMarkers(markers []string) {
+	for _, marker := range markers {
+		if marker == lenientDecodingMarker {
+			b.Lenient = true
+		}
+		if strings.HasPrefix(marker, strings.TrimSuffix(skipValidationMarkerPrefix, ":")) {
+			if reason, found := markerValue(marker, skipValidationMarkerPrefix); found && reason != "" {
+				b.SkipReason = reason
+			} else {
+				b.InvalidMarkers = append(b.InvalidMarkers, marker)
+			}
+		}
+		b.FeatureGates = append(b.FeatureGates, featureGates(marker)...)
+		if message, found := markerValue(marker, expectInvalidMarkerPrefix); found {
+			if b.ExpectInvalid == nil {
+				b.ExpectInvalid = &expectedRejection{Message: message}
+			} else {
+				b.ExpectInvalid.Also = append(b.ExpectInvalid.Also, expectedError{Message: message})
+			}
+		}
+	}
+}
+
+// extractCodeBlocks returns the fenced code blocks of the Markdown document in
+// data, in order, with their markers applied. See examples.ExtractCodeBlocks.
+func extractCodeBlocks(data []byte) []codeBlock {
+	var blocks []codeBlock
+	for _, b := range examples.ExtractCodeBlocks(data) {
+		block := codeBlock{Lang: b.Lang, Line: b.Line, Indent: b.Indent, Content: b.Content}
+		block.applyMarkers(b.Markers)
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+func TestExtractCodeBlocks(t *testing.T) {
+	cases := []struct {
+		name     string
+		markdown string
+		expected []codeBlock
+	}{
+		{
+			name:     "yaml block",
+			markdown: "text\n```yaml\nkind: Pod\napiVersion: v1\n```\n",
+			expected: []codeBlock{{Lang: "yaml", Line: 2, Content: "kind: Pod\napiVersion: v1"}},
+		},
+		{
+			name:     "info string with extra words",
+			markdown: "``` JSON  title\n{}\n```",
+			expected: []codeBlock{{Lang: "json", Line: 1, Content: "{}"}},
+		},
+		{
+			name:     "indented in a list",
+			markdown: "1. Create a pod:\n\n   ```yaml\n   kind: Pod\n   spec:\n     containers: []\n   ```\n",
+			expected: []codeBlock{{Lang: "yaml", Line: 3, Indent: 3, Content: "kind: Pod\nspec:\n  containers: []"}},
+		},
+		{
+			name:     "skip marker",
+			markdown: "<!-- skip-validation: placeholder values -->\n```yaml\nkind: Pod\n```\n\n```yaml\nkind: Service\n```",
+			expected: []codeBlock{
+				{Lang: "yaml", Line: 2, Content: "kind: Pod", SkipReason: "placeholder values"},
+				{Lang: "yaml", Line: 6, Content: "kind: Service"},
+			},
+		},
+		{
+			name:     "skip marker separated by blank line",
+			markdown: "<!-- skip-validation: fragment -->\n\n```yaml\nkind: Pod\n```",
+			expected: []codeBlock{{Lang: "yaml", Line: 3, Content: "kind: Pod", SkipReason: "fragment"}},
+		},
+		{
+			name:     "skip marker without a reason",
+			markdown: "<!-- skip-validation -->\n```yaml\nkind: Pod\n```",
+			expected: []codeBlock{{Lang: "yaml", Line: 2, Content: "kind: Pod", InvalidMarkers: []string{"<!-- skip-validation -->"}}},
+		},
+		{
+			name:     "lenient decoding marker",
+			markdown: "<!-- lenient-decoding -->\n```yaml\nkind: Pod\n```",
+			expected: []codeBlock{{Lang: "yaml", Line: 2, Content: "kind: Pod", Lenient: true}},
+		},
+		{
+			name:     "feature gates marker",
+			markdown: "<!-- feature-gates: A=true, B=false -->\n```yaml\nkind: Pod\n```",
+			expected: []codeBlock{{Lang: "yaml", Line: 2, Content: "kind: Pod", FeatureGates: []string{"A=true", "B=false"}}},
+		},
+		{
+			name:     "expect invalid marker",
+			markdown: "<!-- expect-invalid: must be less than 10 -->\n```yaml\nkind: Pod\n```",
+			expected: []codeBlock{{Lang: "yaml", Line: 2, Content: "kind: Pod", ExpectInvalid: &expectedRejection{Message: "must be less than 10"}}},
+		},
+		{
+			name:     "several markers",
+			markdown: "<!-- lenient-decoding -->\n<!-- expect-invalid: must match -->\n<!-- expect-invalid: must be less than 10 -->\n```yaml\nkind: Pod\n```",
+			expected: []codeBlock{{Lang: "yaml", Line: 4, Content: "kind: Pod", Lenient: true, ExpectInvalid: &expectedRejection{
+				Message: "must match",
+				Also:    []expectedError{{Message: "must be less than 10"}},
+			}}},
+		},
+		{
+			name:     "marker before text",
+			markdown: "<!-- skip-validation: fragment -->\nSome text.\n```yaml\nkind: Pod\n```",
+			expected: []codeBlock{{Lang: "yaml", Line: 3, Content: "kind: Pod"}},
+		},
+		{
+			name:     "fence inside another block is content",
+			markdown: "```\n```yaml\n```\n",
+			expected: []codeBlock{{Lang: "", Line: 1, Content: "```yaml"}},
+		},
+		{
+			name:     "unterminated block",
+			markdown: "```yaml\nkind: Pod\n",
+			expected: nil,
+		},
+	}
+	for _, c := range cases {
+		if actual := extractCodeBlocks([]byte(c.markdown)); !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%s: expected %#v, got %#v", c.name, c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,507 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package examples_test
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// sourceNodeKind is the kind of a sourceNode.
+type sourceNodeKind int
+
+const (
+	scalarNode sourceNodeKind = iota
+	mappingNode
+	sequenceNode
+)
+
+// sourceNode is a node of a YAML document with its position in the source.
+// Mappings hold their keys and values alternately in Content, sequences
+// their items.
+type sourceNode struct {
+	Kind sourceNodeKind
+	// Line and Column are 1-based.
+	Line, Column int
+	// Value is the text of a scalar, without quotes.
+	Value   string
+	Content []*sourceNode
+}
+
+// sourceDocument is a YAML document parsed with the position of every node,
+// used to map the field paths of validation errors back to the source file.
+type sourceDocument struct {
+	root *sourceNode
+	// line and column are added to the positions of the nodes, for documents
+	// that do not start at the beginning of the file, e.g. code blocks in a
+	// Markdown page.
+	line, column int
+}
+
+// parseSourceDocuments parses the YAML or JSON in data and returns its
+// documents in the same order as splitDocuments, i.e. without empty ones.
+// Positions are shifted by line and column.
+func parseSourceDocuments(data []byte, line, column int) []*sourceDocument {
+	var docs []*sourceDocument
+	lines := strings.Split(string(data), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
+	}
+	start := 0
+	for end := 0; end <= len(lines); end++ {
+		// Documents are separated like yaml.NewYAMLReader does.
+		if end < len(lines) && !(strings.HasPrefix(lines[end], "---") && strings.TrimSpace(lines[end][3:]) == "") {
+			continue
+		}
+		p := &sourceParser{lines: lines[:end], line: start}
+		start = end + 1
+		indent := p.next()
+		if indent < 0 {
+			continue
+		}
+		root := p.parseNode(indent, -1)
+		if root.Kind == scalarNode && (root.Value == "null" || root.Value == "~") {
+			continue
+		}
+		docs = append(docs, &sourceDocument{root: root, line: line, column: column})
+	}
+	return docs
+}
+
+// fileSourceDocument returns document i of the YAML or JSON file at path, or
+// nil if the file cannot be read.
+func fileSourceDocument(path string, i int) *sourceDocument {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	docs := parseSourceDocuments(data, 0, 0)
+	if i >= len(docs) {
+		return nil
+	}
+	return docs[i]
+}
+
+// sourceParser builds the sourceNodes of a document. It only knows the
+// structure of the YAML that examples use: block and flow mappings and
+// sequences, and scalars, including multi-line ones, which it does not
+// interpret. Tags, anchors and complex keys are not supported; a document
+// that is not valid YAML results in an incomplete tree.
+type sourceParser struct {
+	lines []string
+	// line is the index of the current line.
+	line int
+}
+
+// next moves to the next line that is neither blank nor a comment, and
+// returns its indentation, or -1 at the end of the document.
+func (p *sourceParser) next() int {
+	for ; p.line < len(p.lines); p.line++ {
+		trimmed := strings.TrimLeft(p.lines[p.line], " ")
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			return len(p.lines[p.line]) - len(trimmed)
+		}
+	}
+	return -1
+}
+
+// skipNested moves past the current line and the following lines that are
+// blank or more indented than parent, e.g. the rest of a multi-line scalar.
+func (p *sourceParser) skipNested(parent int) {
+	for p.line++; p.line < len(p.lines); p.line++ {
+		line := p.lines[p.line]
+		if trimmed := strings.TrimLeft(line, " "); trimmed != "" && len(line)-len(trimmed) <= parent {
+			return
+		}
+	}
+}
+
+// isItem returns true if text starts a block sequence item.
+func isItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// parseNode parses the node at column of the current line, within a block
+// indented by parent.
+func (p *sourceParser) parseNode(column, parent int) *sourceNode {
+	text := p.lines[p.line][column:]
+	switch {
+	case isItem(text):
+		return p.parseSequence(column)
+	case text[0] == '{' || text[0] == '[':
+		return p.parseFlow(column)
+	}
+	if _, _, found := splitKey(text); found {
+		return p.parseMapping(column)
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		// May continue on less indented lines.
+		return p.parseFlow(column)
+	}
+	n := &sourceNode{Kind: scalarNode, Line: p.line + 1, Column: column + 1, Value: scalarValue(text)}
+	p.skipNested(parent)
+	return n
+}
+
+// parseValue parses the value of a key or an item of a block indented by
+// parent when it starts on the next line. The value of a key may be a
+// sequence with the same indentation as the key. A missing value is an empty
+// scalar at line and column.
+func (p *sourceParser) parseValue(parent int, sequenceAllowed bool, line, column int) *sourceNode {
+	indent := p.next()
+	if indent > parent || indent == parent && sequenceAllowed && isItem(p.lines[p.line][indent:]) {
+		return p.parseNode(indent, parent)
+	}
+	return &sourceNode{Kind: scalarNode, Line: line, Column: column}
+}
+
+// parseSequence parses the block sequence whose items start at indent. The
+// first item may follow the dash of another item on the current line.
+func (p *sourceParser) parseSequence(indent int) *sourceNode {
+	n := &sourceNode{Kind: sequenceNode, Line: p.line + 1, Column: indent + 1}
+	for first := true; (first || p.next() == indent) && isItem(p.lines[p.line][indent:]); first = false {
+		line := p.lines[p.line]
+		rest := strings.TrimLeft(line[indent+1:], " ")
+		if rest == "" || rest[0] == '#' {
+			p.line++
+			n.Content = append(n.Content, p.parseValue(indent, false, n.Line, indent+2))
+			continue
+		}
+		n.Content = append(n.Content, p.parseNode(len(line)-len(rest), indent))
+	}
+	return n
+}
+
+// parseMapping parses the block mapping whose keys start at indent. The
+// first key may follow the dash of a sequence item on the current line.
+func (p *sourceParser) parseMapping(indent int) *sourceNode {
+	n := &sourceNode{Kind: mappingNode, Line: p.line + 1, Column: indent + 1}
+	for first := true; first || p.next() == indent; first = false {
+		text := p.lines[p.line][indent:]
+		key, valueColumn, found := splitKey(text)
+		if !found || isItem(text) {
+			break
+		}
+		keyNode := &sourceNode{Kind: scalarNode, Line: p.line + 1, Column: indent + 1, Value: key}
+		var value *sourceNode
+		switch rest := text[valueColumn:]; {
+		case rest == "" || rest[0] == '#':
+			p.line++
+			value = p.parseValue(indent, true, keyNode.Line, indent+valueColumn+1)
+		case strings.IndexByte("{[\"'", rest[0]) >= 0:
+			// Quoted scalars may continue on less indented lines.
+			value = p.parseFlow(indent + valueColumn)
+		default:
+			value = &sourceNode{Kind: scalarNode, Line: p.line + 1, Column: indent + valueColumn + 1, Value: scalarValue(rest)}
+			p.skipNested(indent)
+		}
+		n.Content = append(n.Content, keyNode, value)
+	}
+	return n
+}
+
+// parseFlow parses the flow mapping or sequence, e.g. JSON, or the quoted
+// scalar at column of the current line, and moves to the line after its end.
+func (p *sourceParser) parseFlow(column int) *sourceNode {
+	f := &flowParser{lines: p.lines, line: p.line, column: column}
+	n := f.parseNode()
+	p.line = f.line + 1
+	return n
+}
+
+// flowParser parses flow nodes character by character, across lines.
+type flowParser struct {
+	lines        []string
+	line, column int
+}
+
+// peek skips spaces, line breaks and comments, and returns the next
+// character, or 0 at the end of the document.
+func (f *flowParser) peek() byte {
+	for f.line < len(f.lines) {
+		line := f.lines[f.line]
+		switch {
+		case f.column >= len(line):
+			f.line++
+			f.column = 0
+		case line[f.column] == ' ' || line[f.column] == '\t':
+			f.column++
+		case line[f.column] == '#' && (f.column == 0 || line[f.column-1] == ' '):
+			f.column = len(line)
+		default:
+			return line[f.column]
+		}
+	}
+	return 0
+}
+
+// parseNode parses the flow node at the next character.
+func (f *flowParser) parseNode() *sourceNode {
+	c := f.peek()
+	n := &sourceNode{Kind: scalarNode, Line: f.line + 1, Column: f.column + 1}
+	switch c {
+	case '{', '[':
+		n.Kind = mappingNode
+		end := byte('}')
+		if c == '[' {
+			n.Kind = sequenceNode
+			end = ']'
+		}
+		f.column++
+		for {
+			switch f.peek() {
+			case 0:
+				return n
+			case end:
+				f.column++
+				return n
+			case ',':
+				f.column++
+				continue
+			}
+			item := f.parseNode()
+			if n.Kind == sequenceNode {
+				n.Content = append(n.Content, item)
+				continue
+			}
+			value := &sourceNode{Kind: scalarNode, Line: item.Line, Column: item.Column}
+			if f.peek() == ':' {
+				f.column++
+				value = f.parseNode()
+			}
+			n.Content = append(n.Content, item, value)
+		}
+	case '"', '\'':
+		n.Value = f.parseQuoted(c)
+	default:
+		n.Value = f.parsePlain()
+	}
+	return n
+}
+
+// parseQuoted parses the scalar between quote characters at the next
+// character. It may span lines.
+func (f *flowParser) parseQuoted(quote byte) string {
+	var text []string
+	start, from := f.column, f.column+1
+	for f.line < len(f.lines) {
+		line := f.lines[f.line]
+		if end := closingQuote(line, from, quote); end >= 0 {
+			text = append(text, line[start:end+1])
+			f.column = end + 1
+			break
+		}
+		text = append(text, line[start:])
+		f.line++
+		f.column, start, from = 0, 0, 0
+	}
+	return scalarValue(strings.Join(text, " "))
+}
+
+// parsePlain parses the unquoted scalar at the next character, which ends at
+// the end of the line or before a flow indicator.
+func (f *flowParser) parsePlain() string {
+	line := f.lines[f.line]
+	start := f.column
+	for f.column < len(line) {
+		c := line[f.column]
+		if c == ',' || c == ']' || c == '}' || c == ' ' && strings.HasPrefix(line[f.column:], " #") {
+			break
+		}
+		if c == ':' && (f.column+1 == len(line) || strings.IndexByte(" ,]}", line[f.column+1]) >= 0) && f.column > start {
+			break
+		}
+		f.column++
+	}
+	if f.column == start {
+		// An unexpected character, e.g. a colon without a key.
+		f.column++
+	}
+	return strings.TrimSpace(line[start:f.column])
+}
+
+// closingQuote returns the index of the quote that closes a quoted scalar,
+// looking from index i of line on, or -1 if it continues on the next line.
+func closingQuote(line string, i int, quote byte) int {
+	for ; i < len(line); i++ {
+		switch {
+		case quote == '"' && line[i] == '\\':
+			i++
+		case line[i] == quote && quote == '\'' && i+1 < len(line) && line[i+1] == '\'':
+			i++
+		case line[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// splitKey returns the key that text, a line of a block mapping, starts
+// with, and the index of its value in text.
+func splitKey(text string) (string, int, bool) {
+	end := -1
+	if text[0] == '"' || text[0] == '\'' {
+		quote := closingQuote(text, 1, text[0])
+		if quote < 0 || !strings.HasPrefix(strings.TrimLeft(text[quote+1:], " "), ":") {
+			return "", 0, false
+		}
+		end = quote + 1 + strings.Index(text[quote+1:], ":")
+	} else {
+		for i := 0; i < len(text); i++ {
+			if text[i] == '#' && i > 0 && text[i-1] == ' ' {
+				break
+			}
+			if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+				end = i
+				break
+			}
+		}
+		if end < 0 {
+			return "", 0, false
+		}
+	}
+	value := end + 1
+	for value < len(text) && text[value] == ' ' {
+		value++
+	}
+	return scalarValue(strings.TrimSpace(text[:end])), value, true
+}
+
+// scalarValue returns the value of the scalar in text, without its quotes or
+// trailing comment.
+func scalarValue(text string) string {
+	switch {
+	case strings.HasPrefix(text, "\""):
+		if end := closingQuote(text, 1, '"'); end >= 0 {
+			if value, err := strconv.Unquote(text[:end+1]); err == nil {
+				return value
+			}
+			return text[1:end]
+		}
+	case strings.HasPrefix(text, "'"):
+		if end := closingQuote(text, 1, '\''); end >= 0 {
+			return strings.Replace(text[1:end], "''", "'", -1)
+		}
+	}
+	if i := strings.Index(text, " #"); i >= 0 {
+		text = text[:i]
+	}
+	return strings.TrimSpace(text)
+}
+
+// position returns the line and column of the field at path, e.g.
+// spec.containers[0].image or metadata.labels[app]. If the field does not
+// exist, e.g. because it is required, the position of its closest existing
+// parent is returned.
+func (d *sourceDocument) position(path string) (int, int) {
+	node := d.root
+	for _, step := range splitFieldPath(path) {
+		next := childNode(node, step)
+		if next == nil {
+			break
+		}
+		node = next
+	}
+	return node.Line + d.line, node.Column + d.column
+}
+
+// splitFieldPath splits a path as formatted by field.Path into its field
+// names, map keys and indexes.
+func splitFieldPath(path string) []string {
+	var steps []string
+	for path != "" {
+		switch path[0] {
+		case '.':
+			path = path[1:]
+		case '[':
+			end := strings.Index(path, "]")
+			if end < 0 {
+				return append(steps, path[1:])
+			}
+			steps = append(steps, path[1:end])
+			path = path[end+1:]
+		default:
+			end := strings.IndexAny(path, ".[")
+			if end < 0 {
+				end = len(path)
+			}
+			steps = append(steps, path[:end])
+			path = path[end:]
+		}
+	}
+	return steps
+}
+
+// childNode returns the value of key step in the mapping node n, the item at
+// index step in the sequence node n, or nil.
+func childNode(n *sourceNode, step string) *sourceNode {
+	switch n.Kind {
+	case mappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == step {
+				return n.Content[i+1]
+			}
+		}
+	case sequenceNode:
+		if i, err := strconv.Atoi(step); err == nil && i >= 0 && i < len(n.Content) {
+			return n.Content[i]
+		}
+	}
+	return nil
+}
+
+func TestSourceDocumentPosition(t *testing.T) {
+	source := `# A comment
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  labels:
+    app: nginx
+spec:
+  containers:
+  - name: nginx
+    image: nginx
+    ports:
+    - containerPort: 80
+`
+	docs := parseSourceDocuments([]byte(source), 10, 2)
+	if len(docs) != 1 {
+		t.Fatalf("Expected 1 document, got %d", len(docs))
+	}
+	cases := []struct {
+		path         string
+		line, column int
+	}{
+		{"kind", 14, 9},
+		{"metadata.labels[app]", 17, 12},
+		{"spec.containers[0].image", 21, 14},
+		{"spec.containers[0].ports[0].containerPort", 23, 24},
+		// Missing fields are reported at their closest parent.
+		{"spec.containers[0].resources.limits", 20, 7},
+		{"spec.containers[1]", 20, 5},
+		{"", 13, 3},
+	}
+	for _, c := range cases {
+		line, column := docs[0].position(c.path)
+		if line != c.line || column != c.column {
+			t.Errorf("%q: expected %d:%d, got %d:%d", c.path, c.line, c.column, line, column)
+		}
+	}
+}
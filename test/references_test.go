@@ -0,0 +1,376 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package examples_test
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/kubernetes/pkg/apis/apps"
+	"k8s.io/kubernetes/pkg/apis/autoscaling"
+	api "k8s.io/kubernetes/pkg/apis/core"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/apis/policy"
+)
+
+var referenceScope = flag.String("reference-scope", "file", `Which objects a reference between examples may point to: "file" only checks the documents of each file, "directory" resolves them with all the files of the directory, so each reference is checked once.`)
+
+// exampleObject is a decoded document of an example file.
+type exampleObject struct {
+	File     string
+	Document int
+	Kind     string
+	Object   runtime.Object
+}
+
+// danglingReference is a reference from one object to others that cannot be
+// resolved.
+type danglingReference struct {
+	// From is the index of the object with the reference.
+	From    int
+	Field   *field.Path
+	Message string
+}
+
+// referenceGraph indexes a group of objects, e.g. the documents of a file,
+// to resolve the references between them.
+type referenceGraph struct {
+	objects []exampleObject
+	// kinds counts the objects of each kind. A reference to a kind is only
+	// checked if the group contains at least one object of that kind, since
+	// examples often refer to objects created on the same page by other
+	// means.
+	kinds map[string]int
+	names map[objectKey]int
+}
+
+func newReferenceGraph(objects []exampleObject) *referenceGraph {
+	g := &referenceGraph{objects: objects, kinds: map[string]int{}, names: map[objectKey]int{}}
+	for i, o := range objects {
+		g.kinds[o.Kind]++
+		if accessor, err := meta.Accessor(o.Object); err == nil {
+			g.names[objectKey{o.Kind, namespaceOf(o.Object), accessor.GetName()}] = i
+		}
+	}
+	return g
+}
+
+// namespaceOf returns the namespace of obj, with the empty namespace being
+// the default one.
+func namespaceOf(obj runtime.Object) string {
+	accessor, err := meta.Accessor(obj)
+	if err != nil || accessor.GetNamespace() == "" {
+		return "default"
+	}
+	return accessor.GetNamespace()
+}
+
+// find returns the object of kind called name in namespace.
+func (g *referenceGraph) find(kind, namespace, name string) (runtime.Object, bool) {
+	i, found := g.names[objectKey{kind, namespace, name}]
+	if !found {
+		return nil, false
+	}
+	return g.objects[i].Object, true
+}
+
+// pods returns the pod specs in namespace whose labels match selector.
+func (g *referenceGraph) pods(namespace string, selector labels.Selector) []podSpecRef {
+	var pods []podSpecRef
+	for _, o := range g.objects {
+		if namespaceOf(o.Object) != namespace {
+			continue
+		}
+		for _, ref := range podSpecs(o.Object) {
+			if selector.Matches(labels.Set(ref.Labels)) {
+				pods = append(pods, ref)
+			}
+		}
+	}
+	return pods
+}
+
+// hasPods returns true if the group contains pods or pod templates.
+func (g *referenceGraph) hasPods() bool {
+	for _, o := range g.objects {
+		if len(podSpecs(o.Object)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns the references of the objects that cannot be resolved.
+func (g *referenceGraph) check() []danglingReference {
+	var problems []danglingReference
+	add := func(from int, path *field.Path, format string, args ...interface{}) {
+		problems = append(problems, danglingReference{from, path, fmt.Sprintf(format, args...)})
+	}
+	for i, o := range g.objects {
+		namespace := namespaceOf(o.Object)
+		switch t := o.Object.(type) {
+		case *api.Service:
+			if len(t.Spec.Selector) == 0 || !g.hasPods() {
+				continue
+			}
+			pods := g.pods(namespace, labels.SelectorFromSet(t.Spec.Selector))
+			if len(pods) == 0 {
+				add(i, field.NewPath("spec", "selector"), "selector %v does not match the labels of any pod", labels.Set(t.Spec.Selector))
+				continue
+			}
+			for j, port := range t.Spec.Ports {
+				if port.TargetPort.Type == intstr.String && !hasContainerPort(pods, port.TargetPort.StrVal) {
+					add(i, field.NewPath("spec", "ports").Index(j).Child("targetPort"), "no selected pod has a container port named %q", port.TargetPort.StrVal)
+				}
+			}
+		case *policy.PodDisruptionBudget:
+			if t.Spec.Selector == nil || !g.hasPods() {
+				continue
+			}
+			selector, err := metav1.LabelSelectorAsSelector(t.Spec.Selector)
+			if err != nil {
+				continue
+			}
+			if len(g.pods(namespace, selector)) == 0 {
+				add(i, field.NewPath("spec", "selector"), "selector %v does not match the labels of any pod", selector)
+			}
+		case *apps.StatefulSet:
+			if t.Spec.ServiceName == "" || g.kinds["Service"] == 0 {
+				continue
+			}
+			service, found := g.find("Service", namespace, t.Spec.ServiceName)
+			if !found {
+				add(i, field.NewPath("spec", "serviceName"), "Service %q is not declared", t.Spec.ServiceName)
+			} else if service.(*api.Service).Spec.ClusterIP != api.ClusterIPNone {
+				add(i, field.NewPath("spec", "serviceName"), "Service %q is not headless, set its clusterIP to None", t.Spec.ServiceName)
+			}
+		case *autoscaling.HorizontalPodAutoscaler:
+			target := t.Spec.ScaleTargetRef
+			if g.kinds[target.Kind] == 0 {
+				continue
+			}
+			if _, found := g.find(target.Kind, namespace, target.Name); !found {
+				add(i, field.NewPath("spec", "scaleTargetRef"), "%s %q is not declared", target.Kind, target.Name)
+			}
+		case *extensions.Ingress:
+			if g.kinds["Service"] == 0 {
+				continue
+			}
+			if t.Spec.Backend != nil {
+				problems = append(problems, g.checkIngressBackend(i, namespace, t.Spec.Backend, field.NewPath("spec", "backend"))...)
+			}
+			for j, rule := range t.Spec.Rules {
+				if rule.HTTP == nil {
+					continue
+				}
+				for k := range rule.HTTP.Paths {
+					path := field.NewPath("spec", "rules").Index(j).Child("http", "paths").Index(k).Child("backend")
+					problems = append(problems, g.checkIngressBackend(i, namespace, &rule.HTTP.Paths[k].Backend, path)...)
+				}
+			}
+		}
+
+		if g.kinds["PersistentVolumeClaim"] == 0 {
+			continue
+		}
+		for _, ref := range podSpecs(o.Object) {
+			for j, volume := range ref.Spec.Volumes {
+				if volume.PersistentVolumeClaim == nil {
+					continue
+				}
+				if _, found := g.find("PersistentVolumeClaim", namespace, volume.PersistentVolumeClaim.ClaimName); !found {
+					path := ref.Path.Child("volumes").Index(j).Child("persistentVolumeClaim", "claimName")
+					add(i, path, "PersistentVolumeClaim %q is not declared", volume.PersistentVolumeClaim.ClaimName)
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// checkIngressBackend returns a problem if the Service or the port of an
+// Ingress backend is not declared.
+func (g *referenceGraph) checkIngressBackend(from int, namespace string, backend *extensions.IngressBackend, path *field.Path) []danglingReference {
+	obj, found := g.find("Service", namespace, backend.ServiceName)
+	if !found {
+		return []danglingReference{{from, path.Child("serviceName"), fmt.Sprintf("Service %q is not declared", backend.ServiceName)}}
+	}
+	for _, port := range obj.(*api.Service).Spec.Ports {
+		if backend.ServicePort.Type == intstr.Int && port.Port == backend.ServicePort.IntVal ||
+			backend.ServicePort.Type == intstr.String && port.Name == backend.ServicePort.StrVal {
+			return nil
+		}
+	}
+	return []danglingReference{{from, path.Child("servicePort"), fmt.Sprintf("Service %q has no port %s", backend.ServiceName, backend.ServicePort.String())}}
+}
+
+// hasContainerPort returns true if a container of one of pods has a port
+// called name.
+func hasContainerPort(pods []podSpecRef, name string) bool {
+	for _, pod := range pods {
+		for _, c := range pod.Spec.Containers {
+			for _, port := range c.Ports {
+				if port.Name == name {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// TestExampleReferences checks the references between the documents of each
+// example file, e.g. that the selector of a Service matches the pods of a
+// Deployment declared in the same file. Run with -reference-scope=directory
+// to check all files of a directory together.
+func TestExampleReferences(t *testing.T) {
+	if *referenceScope != "file" && *referenceScope != "directory" {
+		t.Fatalf("Invalid value %q for -reference-scope, expected \"file\" or \"directory\"", *referenceScope)
+	}
+	directories := map[string][]exampleObject{}
+	err := walkConfigFiles(examplesRoot, func(name, path string, docs [][]byte, config *directoryConfig) {
+		var objects []exampleObject
+		for i, data := range docs {
+			typeMeta, err := documentTypeMeta(data)
+			if err != nil {
+				continue
+			}
+			obj, err := decodeDocument(data)
+			if err != nil {
+				// Reported by TestExampleObjectSchemas.
+				continue
+			}
+			objects = append(objects, exampleObject{path, i, typeMeta.Kind, obj})
+		}
+		if *referenceScope == "file" {
+			reportDanglingReferences(t, objects)
+			return
+		}
+		// References to other files are resolved with all the files of
+		// the directory, which also report the references within the file.
+		dir := filepath.Dir(path)
+		directories[dir] = append(directories[dir], objects...)
+	})
+	if err != nil {
+		t.Errorf("Expected no error, Got %v on Path %v", err, examplesRoot)
+	}
+	var dirs []string
+	for dir := range directories {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		reportDanglingReferences(t, directories[dir])
+	}
+}
+
+// reportDanglingReferences reports the references between objects that
+// cannot be resolved.
+func reportDanglingReferences(t *testing.T, objects []exampleObject) {
+	for _, problem := range newReferenceGraph(objects).check() {
+		o := objects[problem.From]
+		f := finding{File: o.File, Document: o.Document, Kind: o.Kind, Field: problem.Field.String()}
+		if src := fileSourceDocument(o.File, o.Document); src != nil {
+			f.Line, f.Column = src.position(f.Field)
+		}
+		reportError(t, f, "%s: %s", f.Field, problem.Message)
+	}
+}
+
+func TestReferenceGraph(t *testing.T) {
+	deployment := &extensions.Deployment{}
+	deployment.Name = "web"
+	deployment.Spec.Template.Labels = map[string]string{"app": "web"}
+	deployment.Spec.Template.Spec.Containers = []api.Container{{Ports: []api.ContainerPort{{Name: "http"}}}}
+	deployment.Spec.Template.Spec.Volumes = []api.Volume{{VolumeSource: api.VolumeSource{PersistentVolumeClaim: &api.PersistentVolumeClaimVolumeSource{ClaimName: "data"}}}}
+
+	service := func(name string, selector map[string]string, targetPort intstr.IntOrString) *api.Service {
+		s := &api.Service{}
+		s.Name = name
+		s.Spec.Selector = selector
+		s.Spec.Ports = []api.ServicePort{{Port: 80, TargetPort: targetPort}}
+		return s
+	}
+	claim := &api.PersistentVolumeClaim{}
+	claim.Name = "data"
+	statefulSet := &apps.StatefulSet{}
+	statefulSet.Spec.ServiceName = "web"
+
+	cases := []struct {
+		name     string
+		objects  []runtime.Object
+		expected []string
+	}{
+		{
+			name:    "valid",
+			objects: []runtime.Object{deployment, claim, service("web", map[string]string{"app": "web"}, intstr.FromString("http"))},
+		},
+		{
+			name:     "selector matches no pod",
+			objects:  []runtime.Object{deployment, service("web", map[string]string{"app": "db"}, intstr.FromInt(80))},
+			expected: []string{"spec.selector"},
+		},
+		{
+			name:     "unknown named port",
+			objects:  []runtime.Object{deployment, service("web", map[string]string{"app": "web"}, intstr.FromString("https"))},
+			expected: []string{"spec.ports[0].targetPort"},
+		},
+		{
+			name:    "service without pods in the file",
+			objects: []runtime.Object{service("web", map[string]string{"app": "db"}, intstr.FromInt(80))},
+		},
+		{
+			name:     "claim not declared",
+			objects:  []runtime.Object{deployment, &api.PersistentVolumeClaim{}},
+			expected: []string{"spec.template.spec.volumes[0].persistentVolumeClaim.claimName"},
+		},
+		{
+			name:     "service is not headless",
+			objects:  []runtime.Object{statefulSet, service("web", nil, intstr.FromInt(80))},
+			expected: []string{"spec.serviceName"},
+		},
+		{
+			name:     "service is not declared",
+			objects:  []runtime.Object{statefulSet, service("db", nil, intstr.FromInt(80))},
+			expected: []string{"spec.serviceName"},
+		},
+	}
+	for _, c := range cases {
+		var objects []exampleObject
+		for i, obj := range c.objects {
+			kind := reflect.TypeOf(obj).Elem().Name()
+			objects = append(objects, exampleObject{"test.yaml", i, kind, obj})
+		}
+		var actual []string
+		for _, problem := range newReferenceGraph(objects).check() {
+			actual = append(actual, problem.Field.String())
+		}
+		if !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%s: expected problems with %v, got %v", c.name, c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package examples_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	api "k8s.io/kubernetes/pkg/apis/core"
+	"k8s.io/kubernetes/plugin/pkg/admission/limitranger"
+)
+
+// expectedRejection describes why the API server rejects an example that a
+// page shows on purpose, e.g. to explain a LimitRange.
+type expectedRejection struct {
+	// LimitRanges are the files, relative to the directory of the example,
+	// of the LimitRanges created before the example. They are enforced on
+	// the example like the LimitRanger admission plugin does.
+	LimitRanges []string `json:"limitRanges"`
+	// Field is the path of the invalid field, e.g. spec.replicas. If empty,
+	// any field matches.
+	Field string `json:"field"`
+	// Message is a fragment of the error message, e.g. the one quoted by the
+	// page. If empty, any message matches.
+	Message string `json:"message"`
+	// Also are the other errors of the example, when the page shows that it
+	// is rejected for several reasons.
+	Also []expectedError `json:"also"`
+}
+
+// expectedError is an error of an expectedRejection.
+type expectedError struct {
+	// Field is the path of the invalid field. If empty, any field matches.
+	Field string `json:"field"`
+	// Message is a fragment of the error message. If empty, any message
+	// matches.
+	Message string `json:"message"`
+}
+
+// rejection is an error of a validation or of an admission plugin.
+type rejection struct {
+	// Field is the path of the invalid field, if known.
+	Field   string
+	Message string
+}
+
+// errors returns every error described by e.
+func (e *expectedRejection) errors() []expectedError {
+	return append([]expectedError{{Field: e.Field, Message: e.Message}}, e.Also...)
+}
+
+func (e *expectedRejection) String() string {
+	var parts []string
+	for _, err := range e.errors() {
+		parts = append(parts, err.String())
+	}
+	return strings.Join(parts, "; ")
+}
+
+// matches returns true if r is the error described by e.
+func (e expectedError) matches(r rejection) bool {
+	return (e.Field == "" || e.Field == r.Field) && strings.Contains(r.Message, e.Message)
+}
+
+func (e expectedError) String() string {
+	var parts []string
+	if e.Field != "" {
+		parts = append(parts, "field "+e.Field)
+	}
+	if e.Message != "" {
+		parts = append(parts, fmt.Sprintf("message %q", e.Message))
+	}
+	if len(parts) == 0 {
+		return "any error"
+	}
+	return strings.Join(parts, " and ")
+}
+
+// loadLimitRanges decodes the LimitRanges in the files of dir.
+func loadLimitRanges(dir string, files []string) ([]*api.LimitRange, error) {
+	var limitRanges []*api.LimitRange
+	for _, file := range files {
+		data, err := ioutil.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+		docs, err := splitDocuments(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		for _, doc := range docs {
+			obj, err := decodeDocument(doc)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", file, err)
+			}
+			limitRange, ok := obj.(*api.LimitRange)
+			if !ok {
+				return nil, fmt.Errorf("%s: expected a LimitRange, got %T", file, obj)
+			}
+			limitRanges = append(limitRanges, limitRange)
+		}
+	}
+	return limitRanges, nil
+}
+
+// admissionRejections returns the errors of the LimitRanger admission plugin
+// for obj with limitRanges. Only pods are checked, since the plugin does not
+// enforce limits on pod templates.
+func admissionRejections(limitRanges []*api.LimitRange, obj runtime.Object) []rejection {
+	pod, ok := obj.(*api.Pod)
+	if !ok {
+		return nil
+	}
+	pod = pod.DeepCopy()
+	var rejections []rejection
+	for _, limitRange := range limitRanges {
+		if err := limitranger.PodMutateLimitFunc(limitRange, pod); err != nil {
+			rejections = append(rejections, rejection{Message: err.Error()})
+		}
+	}
+	for _, limitRange := range limitRanges {
+		if err := limitranger.PodValidateLimitFunc(limitRange, pod); err != nil {
+			rejections = append(rejections, rejection{Message: err.Error()})
+		}
+	}
+	return rejections
+}
+
+// checkExpectedRejection verifies that obj, an example of dir with the
+// validation errors errs, is rejected as described by expect. The test fails
+// if the example is accepted, if an expected error does not occur, or if the
+// example is rejected for another reason.
+func checkExpectedRejection(t *testing.T, f finding, dir string, expect *expectedRejection, obj runtime.Object, errs field.ErrorList) {
+	var rejections []rejection
+	for _, err := range errs {
+		rejections = append(rejections, rejection{Field: err.Field, Message: err.Error()})
+	}
+	limitRanges, err := loadLimitRanges(dir, expect.LimitRanges)
+	if err != nil {
+		reportError(t, f, "invalid limitRanges in %s: %v", directoryConfigFile, err)
+		return
+	}
+	rejections = append(rejections, admissionRejections(limitRanges, obj)...)
+	if len(rejections) == 0 {
+		reportError(t, f, "expected to be rejected with %v as declared in %s, but it is valid", expect, directoryConfigFile)
+		return
+	}
+	// Every error the page shows must occur, and the example must not be
+	// rejected for a reason the page does not explain.
+	expected := expect.errors()
+	occurred := make([]bool, len(expected))
+	for _, r := range rejections {
+		found := false
+		for i, e := range expected {
+			if e.matches(r) {
+				occurred[i] = true
+				found = true
+			}
+		}
+		if !found {
+			f := f
+			f.Field = r.Field
+			reportError(t, f, "rejected for a reason other than %v declared in %s: %s", expect, directoryConfigFile, r.Message)
+		}
+	}
+	for i, e := range expected {
+		if occurred[i] {
+			continue
+		}
+		var messages []string
+		for _, r := range rejections {
+			messages = append(messages, r.Message)
+		}
+		reportError(t, f, "expected to be rejected with %v as declared in %s, got: %s", e, directoryConfigFile, strings.Join(messages, "; "))
+	}
+}
+
+// reportValidation reports the validation errors errs of obj, a document
+// of an example or code block in dir, or checks them against expect if the
+// example is expected to be rejected. obj is nil for custom resources.
+func reportValidation(t *testing.T, f finding, src *sourceDocument, dir string, expect *expectedRejection, obj runtime.Object, errs field.ErrorList) {
+	if expect != nil {
+		checkExpectedRejection(t, f, dir, expect, obj, errs)
+	} else if len(errs) > 0 {
+		reportFieldErrors(t, f, src, errs)
+	}
+}
+
+func TestAdmissionRejections(t *testing.T) {
+	limitRanges, err := loadLimitRanges("../docs/tasks/administer-cluster", []string{"cpu-constraints.yaml"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	pod := func(request, limit string) []byte {
+		return []byte(fmt.Sprintf(`{"apiVersion": "v1", "kind": "Pod", "metadata": {"name": "p"}, "spec": {"containers": [{
+			"name": "c", "image": "nginx", "resources": {"requests": {"cpu": %q}, "limits": {"cpu": %q}}}]}}`, request, limit))
+	}
+	cases := []struct {
+		data     []byte
+		expected string
+	}{
+		{pod("500m", "800m"), ""},
+		{pod("500m", "1500m"), "maximum cpu usage per Container is 800m, but limit is 1500m"},
+		{pod("100m", "800m"), "minimum cpu usage per Container is 200m, but request is 100m"},
+	}
+	for _, c := range cases {
+		obj, err := decodeDocument(c.data)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var messages []string
+		for _, r := range admissionRejections(limitRanges, obj) {
+			messages = append(messages, r.Message)
+		}
+		actual := strings.Join(messages, "; ")
+		if c.expected == "" && actual != "" || !strings.Contains(actual, c.expected) {
+			t.Errorf("Expected %q, got %q", c.expected, actual)
+		}
+	}
+
+	expect := expectedError{Field: "spec.replicas", Message: "must be greater"}
+	if !expect.matches(rejection{Field: "spec.replicas", Message: "spec.replicas: Invalid value: -1: must be greater than or equal to 0"}) {
+		t.Errorf("Expected %v to match", expect)
+	}
+	if expect.matches(rejection{Message: "must be greater than or equal to 0"}) {
+		t.Errorf("Expected %v not to match a rejection without a field", expect)
+	}
+}
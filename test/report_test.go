@@ -0,0 +1,268 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package examples_test
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var (
+	reportJSON  = flag.String("report-json", "", "Write the problems found in the documentation to this file as JSON.")
+	reportJUnit = flag.String("report-junit", "", "Write the problems found in the documentation to this file as JUnit XML.")
+)
+
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+)
+
+// finding is a problem found in a file of the website.
+type finding struct {
+	Test string `json:"test"`
+	File string `json:"file"`
+	// Document is the index of the YAML document within the file, or
+	// within the code block for Markdown files.
+	Document int    `json:"document"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Kind     string `json:"kind,omitempty"`
+	// Field is the path of the invalid field, e.g. spec.containers[0].image.
+	Field    string `json:"field,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// location returns the position of f for use in messages.
+func (f finding) location() string {
+	switch {
+	case f.Line > 0 && f.Column > 0:
+		return fmt.Sprintf("%s:%d:%d", f.File, f.Line, f.Column)
+	case f.Line > 0:
+		return fmt.Sprintf("%s:%d", f.File, f.Line)
+	case f.Document > 0:
+		return fmt.Sprintf("%s (document %d)", f.File, f.Document)
+	}
+	return f.File
+}
+
+// findings collects the problems reported by all tests for the report files.
+var findings struct {
+	sync.Mutex
+	list []finding
+	// tests are the full names of the tests and subtests that reported a
+	// finding.
+	tests map[string]bool
+}
+
+// recordFinding adds f to the report. Findings of subtests are recorded for
+// their top-level test, since subtests are named after their file.
+func recordFinding(t *testing.T, f finding, severity, message string) {
+	f.Test = strings.SplitN(t.Name(), "/", 2)[0]
+	f.Severity = severity
+	f.Message = message
+	findings.Lock()
+	defer findings.Unlock()
+	findings.list = append(findings.list, f)
+	if findings.tests == nil {
+		findings.tests = map[string]bool{}
+	}
+	findings.tests[t.Name()] = true
+}
+
+// hasFindings returns true if t or one of its subtests reported a finding.
+func hasFindings(t *testing.T) bool {
+	findings.Lock()
+	defer findings.Unlock()
+	for name := range findings.tests {
+		if name == t.Name() || strings.HasPrefix(name, t.Name()+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// reportError fails the test with a message about the file described by f
+// and records it in the report.
+func reportError(t *testing.T, f finding, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	t.Errorf("%s: %s", f.location(), message)
+	recordFinding(t, f, severityError, message)
+}
+
+// reportWarning logs a message about the file described by f and records it
+// in the report without failing the test.
+func reportWarning(t *testing.T, f finding, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	t.Logf("%s: %s", f.location(), message)
+	recordFinding(t, f, severityWarning, message)
+}
+
+// reportFieldErrors fails the test with the validation errors of the object
+// described by f and records one finding per invalid field. If src, the source
+// of the object, is not nil, each error is reported at the line and column of
+// its field.
+func reportFieldErrors(t *testing.T, f finding, src *sourceDocument, errs field.ErrorList) {
+	for _, err := range errs {
+		f := f
+		f.Field = err.Field
+		if src != nil {
+			f.Line, f.Column = src.position(err.Field)
+		}
+		t.Errorf("%s: did not validate correctly: %v", f.location(), err)
+		recordFinding(t, f, severityError, err.ErrorBody())
+	}
+}
+
+// TestMain loads the validation cache and writes it and the report files
+// after all tests ran.
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if err := loadValidationCache(*validationCacheFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to load %s, the validation cache is disabled: %v\n", *validationCacheFile, err)
+	}
+	code := m.Run()
+	if err := saveValidationCache(*validationCacheFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to write %s: %v\n", *validationCacheFile, err)
+	}
+	findings.Lock()
+	defer findings.Unlock()
+	// Subtests run concurrently, so their findings are recorded in any
+	// order.
+	sort.SliceStable(findings.list, func(i, j int) bool {
+		a, b := findings.list[i], findings.list[j]
+		if a.Test != b.Test {
+			return a.Test < b.Test
+		}
+		return a.File < b.File
+	})
+	if *reportJSON != "" {
+		if err := writeJSONReport(*reportJSON, findings.list); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to write %s: %v\n", *reportJSON, err)
+			code = 1
+		}
+	}
+	if *reportJUnit != "" {
+		if err := writeJUnitReport(*reportJUnit, findings.list); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to write %s: %v\n", *reportJUnit, err)
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+func writeJSONReport(path string, list []finding) error {
+	if list == nil {
+		list = []finding{}
+	}
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase holds the findings of one test for one file.
+type junitTestCase struct {
+	Name      string         `xml:"name,attr"`
+	ClassName string         `xml:"classname,attr"`
+	Failures  []junitFailure `xml:"failure,omitempty"`
+	SystemOut string         `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message  string `xml:"message,attr"`
+	Type     string `xml:"type,attr"`
+	Contents string `xml:",chardata"`
+}
+
+// writeJUnitReport writes one test suite per test and one test case per file
+// with findings. Errors become failures, warnings are added to the output of
+// the test case.
+func writeJUnitReport(path string, list []finding) error {
+	cases := map[string]map[string]*junitTestCase{}
+	for _, f := range list {
+		if cases[f.Test] == nil {
+			cases[f.Test] = map[string]*junitTestCase{}
+		}
+		c := cases[f.Test][f.File]
+		if c == nil {
+			c = &junitTestCase{Name: f.File, ClassName: f.Test}
+			cases[f.Test][f.File] = c
+		}
+		text := fmt.Sprintf("%s: %s", f.location(), f.Message)
+		if f.Field != "" {
+			text = fmt.Sprintf("%s: %s: %s", f.location(), f.Field, f.Message)
+		}
+		if f.Severity == severityError {
+			c.Failures = append(c.Failures, junitFailure{Message: f.Message, Type: f.Field, Contents: text})
+		} else {
+			c.SystemOut += f.Severity + ": " + text + "\n"
+		}
+	}
+
+	var report junitTestSuites
+	var tests []string
+	for test := range cases {
+		tests = append(tests, test)
+	}
+	sort.Strings(tests)
+	for _, test := range tests {
+		suite := junitTestSuite{Name: test}
+		var files []string
+		for file := range cases[test] {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			c := cases[test][file]
+			suite.Tests++
+			if len(c.Failures) > 0 {
+				suite.Failures++
+			}
+			suite.TestCases = append(suite.TestCases, *c)
+		}
+		report.Suites = append(report.Suites, suite)
+	}
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append([]byte(xml.Header), append(data, '\n')...), 0644)
+}
@@ -0,0 +1,190 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package examples_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/kubernetes/pkg/api/legacyscheme"
+)
+
+// convertedAnnotations are annotations that the conversion of older API
+// versions moves into fields, e.g. the init containers of a pod before they
+// were part of its spec.
+var convertedAnnotations = []string{
+	"pod.alpha.kubernetes.io/init-containers",
+	"pod.beta.kubernetes.io/init-containers",
+}
+
+// droppedField is a field of an example that does not survive a round trip.
+type droppedField struct {
+	Path *field.Path
+	// Known is the name of the field as spelled by the API, if the example
+	// spells it with a different case. The decoder accepts such names but
+	// kubectl validation and later versions of the API server do not.
+	Known string
+}
+
+// droppedFields encodes obj, decoded from the JSON document in data, back to
+// the API version of the document and returns the fields of data that are
+// missing from the result. These are fields the decoder does not know and
+// silently ignored, usually because they are misspelled or misindented.
+func droppedFields(data []byte, obj runtime.Object, gv schema.GroupVersion) ([]droppedField, error) {
+	encoded, err := runtime.Encode(legacyscheme.Codecs.LegacyCodec(gv), obj)
+	if err != nil {
+		return nil, err
+	}
+	var original, roundTripped interface{}
+	if err := json.Unmarshal(data, &original); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(encoded, &roundTripped); err != nil {
+		return nil, err
+	}
+	if root, ok := original.(map[string]interface{}); ok && root["kind"] == "Secret" {
+		// stringData is write-only and merged into data.
+		delete(root, "stringData")
+	}
+	return compareFields(original, roundTripped, nil), nil
+}
+
+// compareFields returns the fields of original, below path, that are
+// missing from encoded.
+func compareFields(original, encoded interface{}, path *field.Path) []droppedField {
+	var dropped []droppedField
+	switch o := original.(type) {
+	case map[string]interface{}:
+		e, ok := encoded.(map[string]interface{})
+		if !ok {
+			// Encoded differently, e.g. an IntOrString or a quantity.
+			return nil
+		}
+		keys := make([]string, 0, len(o))
+		for key := range o {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := path.Child(key)
+			if key == "annotations" {
+				removeConvertedAnnotations(o[key])
+			}
+			value, found := e[key]
+			if found {
+				dropped = append(dropped, compareFields(o[key], value, child)...)
+				continue
+			}
+			// omitempty drops empty and zero values, which are
+			// indistinguishable from an unknown field.
+			if isEmptyValue(o[key]) {
+				continue
+			}
+			d := droppedField{Path: child}
+			for known := range e {
+				if strings.EqualFold(known, key) {
+					d.Known = known
+				}
+			}
+			dropped = append(dropped, d)
+		}
+	case []interface{}:
+		e, ok := encoded.([]interface{})
+		if !ok || len(e) != len(o) {
+			return nil
+		}
+		for i := range o {
+			dropped = append(dropped, compareFields(o[i], e[i], path.Index(i))...)
+		}
+	}
+	return dropped
+}
+
+// removeConvertedAnnotations deletes the convertedAnnotations from the
+// annotations of an object.
+func removeConvertedAnnotations(annotations interface{}) {
+	if m, ok := annotations.(map[string]interface{}); ok {
+		for _, key := range convertedAnnotations {
+			delete(m, key)
+		}
+	}
+}
+
+// isEmptyValue returns true for the JSON values that omitempty omits.
+func isEmptyValue(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+func TestDroppedFields(t *testing.T) {
+	pod := []byte(`{
+  "apiVersion": "v1",
+  "kind": "Pod",
+  "metadata": {
+    "name": "nginx",
+    "labels": {"app": "nginx"},
+    "annotations": {"pod.beta.kubernetes.io/init-containers": "[]"}
+  },
+  "spec": {
+    "hostNetwork": false,
+    "RestartPolicy": "Always",
+    "contianers": [{"name": "nginx", "image": "nginx"}],
+    "containers": [{
+      "name": "nginx",
+      "image": "nginx",
+      "args": [],
+      "resources": {"limits": {"cpu": "0.5"}},
+      "ports": [{"containerPort": 80, "hostport": 8080}]
+    }]
+  }
+}`)
+	obj, err := decodeDocument(pod)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	dropped, err := droppedFields(pod, obj, schema.GroupVersion{Version: "v1"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var actual []string
+	for _, d := range dropped {
+		actual = append(actual, d.Path.String()+"="+d.Known)
+	}
+	expected := []string{"spec.RestartPolicy=restartPolicy", "spec.containers[0].ports[0].hostport=hostPort", "spec.contianers="}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
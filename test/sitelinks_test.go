@@ -0,0 +1,294 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package examples_test
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	// redirectsFile holds the server-side redirects of the website, see
+	// https://www.netlify.com/docs/redirects/.
+	redirectsFile = "../_redirects"
+	// siteURL is the address of the website. Links to it from the website
+	// itself start with /docs/ instead.
+	siteURL = "https://kubernetes.io"
+	// maxRedirects is the number of redirects followed before a link is
+	// considered a loop.
+	maxRedirects = 10
+)
+
+// redirect is a rule of redirectsFile.
+type redirect struct {
+	// Line is the 1-based line number of the rule.
+	Line int
+	// From is the path of the redirected URLs. If it ends with *, it is a
+	// prefix, and the rest of the URL replaces :splat in To.
+	From string
+	To   string
+}
+
+// target returns the URL that url is redirected to by r, if r matches it.
+// Trailing slashes are ignored, as Netlify does.
+func (r redirect) target(url string) (string, bool) {
+	if strings.HasSuffix(r.From, "*") {
+		prefix := strings.TrimSuffix(r.From, "*")
+		if !strings.HasPrefix(url, prefix) {
+			return "", false
+		}
+		return strings.Replace(r.To, ":splat", strings.TrimPrefix(url, prefix), -1), true
+	}
+	if strings.TrimSuffix(url, "/") != strings.TrimSuffix(r.From, "/") {
+		return "", false
+	}
+	return r.To, true
+}
+
+// loadRedirects parses the redirects file at filePath.
+func loadRedirects(filePath string) ([]redirect, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var redirects []redirect
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		redirects = append(redirects, redirect{Line: line, From: fields[0], To: fields[1]})
+	}
+	return redirects, scanner.Err()
+}
+
+// pageURL returns the URL of the file rel, a path relative to siteRoot with
+// slashes, with the pretty permalinks of the website: docs/foo/index.md is
+// published at /docs/foo/ and docs/foo/bar.md at /docs/foo/bar/. Other files
+// are published at their path.
+func pageURL(rel string) string {
+	ext := path.Ext(rel)
+	if ext != ".md" && ext != ".html" {
+		return "/" + rel
+	}
+	base := strings.TrimSuffix(rel, ext)
+	if path.Base(base) == "index" {
+		return "/" + strings.TrimSuffix(base, "index")
+	}
+	return "/" + base + "/"
+}
+
+// frontMatterPermalink returns the permalink in the front matter of the page
+// in data, if any.
+func frontMatterPermalink(data []byte) string {
+	lines := strings.Split(string(data), "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return ""
+	}
+	for _, line := range lines[1:] {
+		if strings.TrimSpace(line) == "---" {
+			break
+		}
+		if strings.HasPrefix(line, "permalink:") {
+			return strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "permalink:")), `"'`)
+		}
+	}
+	return ""
+}
+
+// sitePages maps the URLs of the website to the files below root that are
+// published at them. Directories starting with _ or ., which Jekyll does not
+// publish, are skipped.
+func sitePages(root string) (map[string]string, error) {
+	pages := map[string]string{}
+	err := filepath.Walk(root, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if filePath != root && (strings.HasPrefix(info.Name(), "_") || strings.HasPrefix(info.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		url := pageURL(filepath.ToSlash(rel))
+		if ext := filepath.Ext(filePath); ext == ".md" || ext == ".html" {
+			data, err := ioutil.ReadFile(filePath)
+			if err != nil {
+				return err
+			}
+			if permalink := frontMatterPermalink(data); permalink != "" {
+				url = permalink
+			}
+		}
+		pages[url] = filePath
+		return nil
+	})
+	return pages, err
+}
+
+// siteLink is the resolution of a link within the website.
+type siteLink struct {
+	// Final is the URL that the link ends at after redirects.
+	Final string
+	// Redirected is true if Final is not the URL of the link.
+	Redirected bool
+	// Found is true if a page is published at Final, or if Final is outside
+	// of the website.
+	Found bool
+}
+
+// resolveSiteLink follows the redirects of url, a path within the website,
+// until it reaches a page. As on Netlify, redirects only apply to URLs
+// without a page, and URLs without a trailing slash are redirected to the
+// page with it. The fragment of url is kept.
+func resolveSiteLink(url string, pages map[string]string, redirects []redirect) siteLink {
+	fragment := ""
+	if i := strings.Index(url, "#"); i >= 0 {
+		url, fragment = url[:i], url[i:]
+	}
+	link := siteLink{Final: url}
+	for i := 0; i < maxRedirects; i++ {
+		if !strings.HasPrefix(link.Final, "/") {
+			// Outside of the website.
+			link.Found = true
+			break
+		}
+		if _, found := pages[link.Final]; found {
+			link.Found = true
+			break
+		}
+		next := ""
+		if _, found := pages[link.Final+"/"]; found {
+			next = link.Final + "/"
+		} else {
+			for _, r := range redirects {
+				if to, matches := r.target(link.Final); matches {
+					next = to
+					break
+				}
+			}
+		}
+		if next == "" {
+			break
+		}
+		link.Final = strings.TrimPrefix(next, siteURL)
+		link.Redirected = true
+	}
+	if link.Found && !strings.Contains(link.Final, "#") {
+		link.Final += fragment
+	}
+	return link
+}
+
+// TestGlossaryLinks checks that the full-link of every glossary term within
+// the website points at a page, without redirects.
+func TestGlossaryLinks(t *testing.T) {
+	pages, err := sitePages(siteRoot)
+	if err != nil {
+		t.Fatalf("Unable to list the pages of the website: %v", err)
+	}
+	redirects, err := loadRedirects(redirectsFile)
+	if err != nil {
+		t.Fatalf("Unable to read %s: %v", redirectsFile, err)
+	}
+	glossary, err := loadGlossaryFiles(t, glossaryDir)
+	if err != nil {
+		t.Fatalf("Unable to read directory %s: %v", glossaryDir, err)
+	}
+	for _, g := range glossary {
+		fullLink := g.Term.FullLink
+		url := strings.TrimPrefix(fullLink, siteURL)
+		if !strings.HasPrefix(url, "/") {
+			continue
+		}
+		f := g.finding("full-link")
+		link := resolveSiteLink(url, pages, redirects)
+		switch {
+		case !link.Found:
+			// Pages are often linked with the extension of their source.
+			if ext := path.Ext(link.Final); ext == ".md" || ext == ".html" {
+				if suggestion := resolveSiteLink(strings.TrimSuffix(link.Final, ext)+"/", pages, redirects); suggestion.Found {
+					reportError(t, f, "full-link %s does not exist, use %s", fullLink, suggestion.Final)
+					continue
+				}
+			}
+			reportError(t, f, "full-link %s does not exist", fullLink)
+		case link.Redirected:
+			reportError(t, f, "full-link %s is redirected, use %s", fullLink, link.Final)
+		case url != fullLink:
+			reportError(t, f, "full-link %s is within the website, use %s", fullLink, link.Final)
+		}
+	}
+}
+
+func TestResolveSiteLink(t *testing.T) {
+	for _, c := range []struct {
+		rel, url string
+	}{
+		{"docs/concepts/index.md", "/docs/concepts/"},
+		{"docs/concepts/workloads/pods/pod.md", "/docs/concepts/workloads/pods/pod/"},
+		{"docs/home/index.html", "/docs/home/"},
+		{"docs/concepts/workloads/pods/pod.yaml", "/docs/concepts/workloads/pods/pod.yaml"},
+	} {
+		if actual := pageURL(c.rel); actual != c.url {
+			t.Errorf("Expected %s to be published at %s, got %s", c.rel, c.url, actual)
+		}
+	}
+
+	pages := map[string]string{
+		"/docs/concepts/workloads/pods/pod/":                 "pod.md",
+		"/docs/reference/generated/kube-proxy/":              "kube-proxy.md",
+		"/docs/reference/generated/kube-controller-manager/": "kube-controller-manager.md",
+	}
+	redirects := []redirect{
+		{From: "/docs/admin/kube-controller-manager/", To: "/docs/reference/generated/kube-controller-manager/"},
+		{From: "/docs/user-guide/pods/*", To: "/docs/concepts/workloads/pods/:splat"},
+		{From: "/docs/admin/kube-proxy/", To: "/docs/reference/generated/kube-proxy"},
+		{From: "/docs/api-reference/", To: "https://kubernetes.io/docs/reference/"},
+		{From: "/loop/", To: "/loop"},
+	}
+	for _, c := range []struct {
+		url      string
+		expected siteLink
+	}{
+		{"/docs/concepts/workloads/pods/pod/#pod-lifetime", siteLink{Final: "/docs/concepts/workloads/pods/pod/#pod-lifetime", Found: true}},
+		{"/docs/reference/generated/kube-proxy", siteLink{Final: "/docs/reference/generated/kube-proxy/", Redirected: true, Found: true}},
+		{"/docs/admin/kube-controller-manager", siteLink{Final: "/docs/reference/generated/kube-controller-manager/", Redirected: true, Found: true}},
+		{"/docs/user-guide/pods/pod/", siteLink{Final: "/docs/concepts/workloads/pods/pod/", Redirected: true, Found: true}},
+		{"/docs/admin/kube-proxy/", siteLink{Final: "/docs/reference/generated/kube-proxy/", Redirected: true, Found: true}},
+		{"/docs/api-reference/", siteLink{Final: "/docs/reference/", Redirected: true}},
+		{"/docs/concepts/workloads/pods/pod.md", siteLink{Final: "/docs/concepts/workloads/pods/pod.md"}},
+		{"/loop/", siteLink{Final: "/loop", Redirected: true}},
+	} {
+		if actual := resolveSiteLink(c.url, pages, redirects); !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("Expected %s to resolve to %+v, got %+v", c.url, c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package examples_test
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var strictDecoding = flag.Bool("strict-decoding", true, "Fail on unknown and duplicate keys in examples, unless they opt out with lenientDecoding or "+lenientDecodingMarker+".")
+
+// decodingProblem is a key of a document that the lenient decoder of the API
+// server accepts, but that is almost always a mistake.
+type decodingProblem struct {
+	Path *field.Path
+	// Line and Column are the position of the key, or 0 if unknown.
+	Line, Column int
+	Message      string
+}
+
+// duplicateKeys returns the keys of the mappings of d that are defined more
+// than once. The decoder silently keeps the last value.
+func (d *sourceDocument) duplicateKeys() []decodingProblem {
+	var problems []decodingProblem
+	var walk func(n *sourceNode, path *field.Path)
+	walk = func(n *sourceNode, path *field.Path) {
+		switch n.Kind {
+		case mappingNode:
+			seen := map[string]bool{}
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				key := n.Content[i]
+				if seen[key.Value] {
+					problems = append(problems, decodingProblem{
+						Path:    path.Child(key.Value),
+						Line:    key.Line + d.line,
+						Column:  key.Column + d.column,
+						Message: "duplicate key, only the last value is used",
+					})
+				}
+				seen[key.Value] = true
+				walk(n.Content[i+1], path.Child(key.Value))
+			}
+		case sequenceNode:
+			for i, item := range n.Content {
+				walk(item, path.Index(i))
+			}
+		}
+	}
+	walk(d.root, nil)
+	return problems
+}
+
+// strictDecodingProblems returns the duplicate keys of the document in data,
+// parsed as src, and its unknown fields, which are dropped when obj, the
+// decoded document, is encoded back to the API version of the document.
+func strictDecodingProblems(data []byte, src *sourceDocument, obj runtime.Object) ([]decodingProblem, error) {
+	if src == nil {
+		return nil, fmt.Errorf("could not be parsed to look for duplicate keys")
+	}
+	problems := src.duplicateKeys()
+	typeMeta, err := documentTypeMeta(data)
+	if err != nil {
+		return nil, err
+	}
+	gv, err := schema.ParseGroupVersion(typeMeta.APIVersion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid apiVersion %q: %v", typeMeta.APIVersion, err)
+	}
+	dropped, err := droppedFields(data, obj, gv)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode object: %v", err)
+	}
+	for _, d := range dropped {
+		p := decodingProblem{Path: d.Path}
+		p.Line, p.Column = src.position(d.Path.String())
+		if d.Known != "" {
+			p.Message = fmt.Sprintf("field names are case-sensitive, use %q", d.Known)
+		} else {
+			p.Message = fmt.Sprintf("unknown field, it is ignored by %s %s", typeMeta.APIVersion, typeMeta.Kind)
+		}
+		problems = append(problems, p)
+	}
+	return problems, nil
+}
+
+// reportStrictDecoding reports the duplicate keys and unknown fields of the
+// document in data, unless strict decoding is disabled with -strict-decoding
+// or for the example with lenient.
+func reportStrictDecoding(t *testing.T, f finding, src *sourceDocument, data []byte, obj runtime.Object, lenient bool) {
+	if !*strictDecoding || lenient {
+		return
+	}
+	problems, err := strictDecodingProblems(data, src, obj)
+	if err != nil {
+		reportError(t, f, "%v", err)
+		return
+	}
+	for _, p := range problems {
+		f.Field = p.Path.String()
+		f.Line, f.Column = p.Line, p.Column
+		reportError(t, f, "%s: %s", f.Field, p.Message)
+	}
+}
+
+func TestStrictDecodingProblems(t *testing.T) {
+	source := `apiVersion: v1
+kind: Pod
+metadata:
+  name: nginx
+  name: web
+spec:
+  containers:
+  - name: nginx
+    image: nginx
+    Image: nginx:1.7.9
+  contianers: []
+  volumes:
+  - name: data
+    emptydir: {medium: Memory}
+`
+	docs, err := splitDocuments([]byte(source))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	sources := parseSourceDocuments([]byte(source), 0, 0)
+	obj, err := decodeDocument(docs[0])
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	problems, err := strictDecodingProblems(docs[0], sources[0], obj)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var actual []string
+	for _, p := range problems {
+		actual = append(actual, fmt.Sprintf("%d:%d %s", p.Line, p.Column, p.Path))
+	}
+	// Empty values are dropped by the encoder too, so contianers is not
+	// reported.
+	expected := []string{
+		"5:3 metadata.name",
+		"10:12 spec.containers[0].Image",
+		"14:15 spec.volumes[0].emptydir",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
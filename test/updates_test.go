@@ -0,0 +1,648 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package examples_test
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/kubernetes/pkg/api/legacyscheme"
+	"k8s.io/kubernetes/pkg/apis/apps"
+	apps_validation "k8s.io/kubernetes/pkg/apis/apps/validation"
+	"k8s.io/kubernetes/pkg/apis/autoscaling"
+	autoscaling_validation "k8s.io/kubernetes/pkg/apis/autoscaling/validation"
+	"k8s.io/kubernetes/pkg/apis/batch"
+	batch_validation "k8s.io/kubernetes/pkg/apis/batch/validation"
+	api "k8s.io/kubernetes/pkg/apis/core"
+	"k8s.io/kubernetes/pkg/apis/core/validation"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	ext_validation "k8s.io/kubernetes/pkg/apis/extensions/validation"
+	"k8s.io/kubernetes/pkg/apis/policy"
+	policy_validation "k8s.io/kubernetes/pkg/apis/policy/validation"
+	"k8s.io/kubernetes/pkg/apis/storage"
+	storage_validation "k8s.io/kubernetes/pkg/apis/storage/validation"
+)
+
+var checkUpdates = flag.Bool("check-updates", false, "Store the examples of each directory, in order, in an in-memory object store, report the updates and namespaces that an API server would reject and the ConfigMaps, Secrets, ServiceAccounts and PersistentVolumeClaims that pods refer to but no example or page creates.")
+
+// initialNamespaces exist in every cluster.
+var initialNamespaces = map[string]bool{
+	"":            true,
+	"default":     true,
+	"kube-public": true,
+	"kube-system": true,
+}
+
+// objectKey identifies an object in an objectStore.
+type objectKey struct {
+	Kind, Namespace, Name string
+}
+
+// objectStore holds the examples of a directory stored in order, the way a
+// reader of the page creates them one after the other. It is not an API
+// server and does not run admission plugins: it only checks that namespaces
+// exist, like the NamespaceLifecycle plugin, validates an object that
+// replaces another one with the same name as an update and resolves the
+// objects that pods refer to.
+type objectStore struct {
+	objects map[objectKey]runtime.Object
+	// created are the objects created outside of the examples, e.g. with
+	// kubectl create namespace, keyed by kind and name only.
+	created map[objectKey]bool
+	version int
+}
+
+func newObjectStore(created map[objectKey]bool) *objectStore {
+	return &objectStore{objects: map[objectKey]runtime.Object{}, created: created}
+}
+
+// put creates or updates obj, an internal object decoded from the given
+// group, version and kind, and returns the errors that an API server would
+// return for them. A rejected object is not stored.
+func (s *objectStore) put(gvk schema.GroupVersionKind, obj runtime.Object) field.ErrorList {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return field.ErrorList{field.InternalError(field.NewPath("metadata"), err)}
+	}
+	if accessor.GetName() == "" {
+		// Created with generateName, never updated.
+		return nil
+	}
+	mapping, err := legacyscheme.Registry.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return field.ErrorList{field.InternalError(field.NewPath("kind"), err)}
+	}
+	namespace := accessor.GetNamespace()
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		if namespace == "" {
+			namespace = "default"
+			accessor.SetNamespace(namespace)
+		}
+		if !initialNamespaces[namespace] {
+			if _, found := s.resolve("Namespace", "", namespace); !found {
+				return field.ErrorList{field.NotFound(field.NewPath("metadata", "namespace"), namespace)}
+			}
+		}
+	}
+
+	key := objectKey{gvk.Kind, namespace, accessor.GetName()}
+	old, found := s.objects[key]
+	if !found {
+		s.version++
+		accessor.SetResourceVersion(strconv.Itoa(s.version))
+		s.objects[key] = obj
+		return nil
+	}
+	// kubectl apply patches the current version of the object.
+	oldAccessor, err := meta.Accessor(old)
+	if err != nil {
+		return field.ErrorList{field.InternalError(field.NewPath("metadata"), err)}
+	}
+	accessor.SetResourceVersion(oldAccessor.GetResourceVersion())
+	if errs := validateUpdate(obj, old); len(errs) > 0 {
+		return errs
+	}
+	s.objects[key] = obj
+	return nil
+}
+
+// resolve returns the object of kind called name in namespace and whether it
+// exists. The objects created by the pages exist in every namespace, but
+// their content is unknown.
+func (s *objectStore) resolve(kind, namespace, name string) (runtime.Object, bool) {
+	if obj, found := s.objects[objectKey{kind, namespace, name}]; found {
+		return obj, true
+	}
+	return nil, s.created[objectKey{kind, "", name}]
+}
+
+// podReference is a reference of a pod spec to another object that the pod
+// needs to start, e.g. the ConfigMap of a volume.
+type podReference struct {
+	Kind, Name string
+	// Path is the field that names the object.
+	Path *field.Path
+	// Key is the key of the ConfigMap or Secret that the pod uses, if any,
+	// at KeyPath.
+	Key     string
+	KeyPath *field.Path
+}
+
+// podReferences returns the references of the pod spec of ref to
+// ConfigMaps, Secrets, ServiceAccounts and PersistentVolumeClaims. Optional
+// references are left out.
+func podReferences(ref podSpecRef) []podReference {
+	var refs []podReference
+	optional := func(o *bool) bool {
+		return o != nil && *o
+	}
+	// volume adds the object of a volume and the keys it projects.
+	volume := func(kind, name string, items []api.KeyToPath, path, namePath *field.Path) {
+		refs = append(refs, podReference{Kind: kind, Name: name, Path: namePath})
+		for i, item := range items {
+			refs = append(refs, podReference{kind, name, namePath, item.Key, path.Child("items").Index(i).Child("key")})
+		}
+	}
+	for i, v := range ref.Spec.Volumes {
+		path := ref.Path.Child("volumes").Index(i)
+		switch {
+		case v.ConfigMap != nil && !optional(v.ConfigMap.Optional):
+			path = path.Child("configMap")
+			volume("ConfigMap", v.ConfigMap.Name, v.ConfigMap.Items, path, path.Child("name"))
+		case v.Secret != nil && !optional(v.Secret.Optional):
+			path = path.Child("secret")
+			volume("Secret", v.Secret.SecretName, v.Secret.Items, path, path.Child("secretName"))
+		case v.PersistentVolumeClaim != nil:
+			refs = append(refs, podReference{Kind: "PersistentVolumeClaim", Name: v.PersistentVolumeClaim.ClaimName, Path: path.Child("persistentVolumeClaim", "claimName")})
+		}
+	}
+	containers := func(containers []api.Container, path *field.Path) {
+		for i, c := range containers {
+			for j, env := range c.EnvFrom {
+				envPath := path.Index(i).Child("envFrom").Index(j)
+				if env.ConfigMapRef != nil && !optional(env.ConfigMapRef.Optional) {
+					refs = append(refs, podReference{Kind: "ConfigMap", Name: env.ConfigMapRef.Name, Path: envPath.Child("configMapRef", "name")})
+				}
+				if env.SecretRef != nil && !optional(env.SecretRef.Optional) {
+					refs = append(refs, podReference{Kind: "Secret", Name: env.SecretRef.Name, Path: envPath.Child("secretRef", "name")})
+				}
+			}
+			for j, env := range c.Env {
+				if env.ValueFrom == nil {
+					continue
+				}
+				envPath := path.Index(i).Child("env").Index(j).Child("valueFrom")
+				if key := env.ValueFrom.ConfigMapKeyRef; key != nil && !optional(key.Optional) {
+					refs = append(refs, podReference{"ConfigMap", key.Name, envPath.Child("configMapKeyRef", "name"), key.Key, envPath.Child("configMapKeyRef", "key")})
+				}
+				if key := env.ValueFrom.SecretKeyRef; key != nil && !optional(key.Optional) {
+					refs = append(refs, podReference{"Secret", key.Name, envPath.Child("secretKeyRef", "name"), key.Key, envPath.Child("secretKeyRef", "key")})
+				}
+			}
+		}
+	}
+	containers(ref.Spec.InitContainers, ref.Path.Child("initContainers"))
+	containers(ref.Spec.Containers, ref.Path.Child("containers"))
+	// Every namespace has a default ServiceAccount.
+	if name := ref.Spec.ServiceAccountName; name != "" && name != "default" {
+		refs = append(refs, podReference{Kind: "ServiceAccount", Name: name, Path: ref.Path.Child("serviceAccountName")})
+	}
+	return refs
+}
+
+// hasKey returns true if obj, a ConfigMap or a Secret, has key.
+func hasKey(obj runtime.Object, key string) bool {
+	switch t := obj.(type) {
+	case *api.ConfigMap:
+		_, inData := t.Data[key]
+		_, inBinaryData := t.BinaryData[key]
+		return inData || inBinaryData
+	case *api.Secret:
+		_, found := t.Data[key]
+		return found
+	}
+	return true
+}
+
+// missingReferences returns the references of the pods of objects to the
+// objects that are neither stored nor created by the pages, or to keys that
+// the stored objects lack. Since the whole directory is stored first, a pod
+// may refer to an object of a later file.
+func (s *objectStore) missingReferences(objects []exampleObject) []danglingReference {
+	var problems []danglingReference
+	for i, o := range objects {
+		namespace := namespaceOf(o.Object)
+		for _, ref := range podSpecs(o.Object) {
+			// The keys of a volume name the object again.
+			reported := map[string]bool{}
+			for _, r := range podReferences(ref) {
+				obj, found := s.resolve(r.Kind, namespace, r.Name)
+				switch {
+				case !found && !reported[r.Path.String()]:
+					reported[r.Path.String()] = true
+					problems = append(problems, danglingReference{i, r.Path, fmt.Sprintf("%s %q is not created by the examples or pages of the directory", r.Kind, r.Name)})
+				case obj != nil && r.Key != "" && !hasKey(obj, r.Key):
+					problems = append(problems, danglingReference{i, r.KeyPath, fmt.Sprintf("%s %q has no key %q", r.Kind, r.Name, r.Key)})
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// createObjectRegexp matches the commands that create an object in a page,
+// e.g. kubectl create namespace qos-example or kubectl create secret generic
+// db-user-pass --from-file=./username.txt.
+var createObjectRegexp = regexp.MustCompile(`kubectl\s+create\s+(namespace|ns|configmap|cm|serviceaccount|sa|secret\s+(?:generic|docker-registry|tls))\s+([a-z0-9.-]+)`)
+
+// createdKinds are the kinds of the objects created by createObjectRegexp.
+var createdKinds = map[string]string{
+	"namespace":      "Namespace",
+	"ns":             "Namespace",
+	"configmap":      "ConfigMap",
+	"cm":             "ConfigMap",
+	"serviceaccount": "ServiceAccount",
+	"sa":             "ServiceAccount",
+}
+
+// exampleLinkRegexp matches the links of a page to an example, e.g. in
+// kubectl apply -f https://k8s.io/docs/tasks/run-application/deployment.yaml.
+var exampleLinkRegexp = regexp.MustCompile(`docs/[\w./-]+\.(?:yaml|json)`)
+
+// directoryPages returns the Markdown pages that may create the objects that
+// the examples of a directory refer to, keyed by directory: the pages of the
+// directory and the pages that include or link to one of its examples.
+func directoryPages() (map[string][]string, error) {
+	pages := map[string]map[string]bool{}
+	add := func(dir, page string) {
+		if pages[dir] == nil {
+			pages[dir] = map[string]bool{}
+		}
+		pages[dir][page] = true
+	}
+	for _, root := range markdownRoots {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || filepath.Ext(path) != ".md" {
+				return err
+			}
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			add(filepath.Dir(path), path)
+			for _, include := range extractCodeIncludes(data) {
+				add(filepath.Dir(filepath.Join(filepath.Dir(path), filepath.FromSlash(include.File))), path)
+			}
+			for _, link := range exampleLinkRegexp.FindAllString(string(data), -1) {
+				add(filepath.Dir(filepath.Join(siteRoot, filepath.FromSlash(link))), path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	result := map[string][]string{}
+	for dir, set := range pages {
+		for page := range set {
+			result[dir] = append(result[dir], page)
+		}
+		sort.Strings(result[dir])
+	}
+	return result, nil
+}
+
+// pageObjects returns the objects that pages create, keyed by kind and name:
+// the objects of their kubectl create commands and of their yaml and json
+// code blocks.
+func pageObjects(pages []string) (map[objectKey]bool, error) {
+	objects := map[objectKey]bool{}
+	for _, page := range pages {
+		data, err := ioutil.ReadFile(page)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range createObjectRegexp.FindAllStringSubmatch(string(data), -1) {
+			kind, found := createdKinds[m[1]]
+			if !found {
+				kind = "Secret"
+			}
+			objects[objectKey{kind, "", m[2]}] = true
+		}
+		for _, block := range extractCodeBlocks(data) {
+			docs := [][]byte{[]byte(block.Content)}
+			switch block.Lang {
+			case "json":
+			case "yaml", "yml":
+				if docs, err = splitDocuments(docs[0]); err != nil {
+					// Not an object, e.g. a fragment.
+					continue
+				}
+			default:
+				continue
+			}
+			for _, doc := range docs {
+				var obj struct {
+					Kind     string `json:"kind"`
+					Metadata struct {
+						Name string `json:"name"`
+					} `json:"metadata"`
+				}
+				if json.Unmarshal(doc, &obj) == nil && obj.Kind != "" && obj.Metadata.Name != "" {
+					objects[objectKey{obj.Kind, "", obj.Metadata.Name}] = true
+				}
+			}
+		}
+	}
+	return objects, nil
+}
+
+// validateUpdate validates obj as an update of old, e.g. that immutable
+// fields did not change. Kinds without update validation are accepted.
+func validateUpdate(obj, old runtime.Object) field.ErrorList {
+	switch t := obj.(type) {
+	case *api.ConfigMap:
+		return validation.ValidateConfigMapUpdate(t, old.(*api.ConfigMap))
+	case *api.Namespace:
+		return validation.ValidateNamespaceUpdate(t, old.(*api.Namespace))
+	case *api.PersistentVolume:
+		return validation.ValidatePersistentVolumeUpdate(t, old.(*api.PersistentVolume))
+	case *api.PersistentVolumeClaim:
+		return validation.ValidatePersistentVolumeClaimUpdate(t, old.(*api.PersistentVolumeClaim))
+	case *api.Pod:
+		return validation.ValidatePodUpdate(t, old.(*api.Pod))
+	case *api.PodTemplate:
+		return validation.ValidatePodTemplateUpdate(t, old.(*api.PodTemplate))
+	case *api.ReplicationController:
+		return validation.ValidateReplicationControllerUpdate(t, old.(*api.ReplicationController))
+	case *api.ResourceQuota:
+		return validation.ValidateResourceQuotaUpdate(t, old.(*api.ResourceQuota))
+	case *api.Secret:
+		return validation.ValidateSecretUpdate(t, old.(*api.Secret))
+	case *api.Service:
+		return validation.ValidateServiceUpdate(t, old.(*api.Service))
+	case *api.ServiceAccount:
+		return validation.ValidateServiceAccountUpdate(t, old.(*api.ServiceAccount))
+	case *apps.StatefulSet:
+		return apps_validation.ValidateStatefulSetUpdate(t, old.(*apps.StatefulSet))
+	case *autoscaling.HorizontalPodAutoscaler:
+		return autoscaling_validation.ValidateHorizontalPodAutoscalerUpdate(t, old.(*autoscaling.HorizontalPodAutoscaler))
+	case *batch.CronJob:
+		return batch_validation.ValidateCronJobUpdate(t, old.(*batch.CronJob))
+	case *batch.Job:
+		return batch_validation.ValidateJobUpdate(t, old.(*batch.Job))
+	case *extensions.DaemonSet:
+		oldDaemonSet := old.(*extensions.DaemonSet)
+		// Like the DaemonSet strategy of the API server.
+		t.Spec.TemplateGeneration = oldDaemonSet.Spec.TemplateGeneration
+		if !apiequality.Semantic.DeepEqual(t.Spec.Template, oldDaemonSet.Spec.Template) {
+			t.Spec.TemplateGeneration++
+		}
+		return ext_validation.ValidateDaemonSetUpdate(t, oldDaemonSet)
+	case *extensions.Deployment:
+		return ext_validation.ValidateDeploymentUpdate(t, old.(*extensions.Deployment))
+	case *extensions.Ingress:
+		return ext_validation.ValidateIngressUpdate(t, old.(*extensions.Ingress))
+	case *extensions.PodSecurityPolicy:
+		return ext_validation.ValidatePodSecurityPolicyUpdate(old.(*extensions.PodSecurityPolicy), t)
+	case *extensions.ReplicaSet:
+		return ext_validation.ValidateReplicaSetUpdate(t, old.(*extensions.ReplicaSet))
+	case *policy.PodDisruptionBudget:
+		return policy_validation.ValidatePodDisruptionBudgetUpdate(t, old.(*policy.PodDisruptionBudget))
+	case *storage.StorageClass:
+		return storage_validation.ValidateStorageClassUpdate(t, old.(*storage.StorageClass))
+	}
+	return nil
+}
+
+// TestExampleUpdates stores the examples of each directory, in the order of
+// their file names, in an objectStore, then checks that the ConfigMaps,
+// Secrets, ServiceAccounts and PersistentVolumeClaims that their pods refer
+// to are created by the examples or the pages of the directory. It only runs
+// with -check-updates.
+//
+// It does not apply the examples to an API server, not even an in-process
+// one: the API server of this release has no server-side dry run, and it
+// needs a running etcd. Defaulting, create validation and the LimitRanger
+// plugin are covered by TestExampleObjectSchemas and references between
+// objects of a file by TestExampleReferences; other admission plugins are
+// not run. Since pages often delete objects between two examples, the
+// rejected updates and namespaces are reported as warnings, while a missing
+// reference fails the test.
+func TestExampleUpdates(t *testing.T) {
+	if !*checkUpdates {
+		t.Skip("run with -check-updates to check the examples as updates")
+	}
+	pages, err := directoryPages()
+	if err != nil {
+		t.Fatalf("Unable to read pages: %v", err)
+	}
+	stores := map[string]*objectStore{}
+	directories := map[string][]exampleObject{}
+	err = walkConfigFiles(examplesRoot, func(name, path string, docs [][]byte, config *directoryConfig) {
+		dir := filepath.Dir(path)
+		if stores[dir] == nil {
+			created, err := pageObjects(pages[dir])
+			if err != nil {
+				reportError(t, finding{File: dir}, "unable to read pages: %v", err)
+				created = map[objectKey]bool{}
+			}
+			for _, external := range config.External {
+				parts := strings.SplitN(external, "/", 2)
+				if len(parts) != 2 {
+					reportError(t, finding{File: filepath.Join(dir, directoryConfigFile)}, "invalid external object %q, expected Kind/name", external)
+					continue
+				}
+				created[objectKey{parts[0], "", parts[1]}] = true
+			}
+			stores[dir] = newObjectStore(created)
+		}
+		store := stores[dir]
+		example := config.example(filepath.Base(path))
+		if example.ExpectInvalid != nil {
+			// The page shows that the example is not created.
+			return
+		}
+		err := withExampleConfig(example, func() {
+			for i, data := range docs {
+				typeMeta, err := documentTypeMeta(data)
+				if err != nil {
+					continue
+				}
+				obj, err := decodeDocument(data)
+				if err != nil {
+					// Reported by TestExampleObjectSchemas.
+					continue
+				}
+				directories[dir] = append(directories[dir], exampleObject{path, i, typeMeta.Kind, obj})
+				errs := store.put(schema.FromAPIVersionAndKind(typeMeta.APIVersion, typeMeta.Kind), obj)
+				if len(errs) == 0 {
+					continue
+				}
+				src := fileSourceDocument(path, i)
+				for _, err := range errs {
+					f := finding{File: path, Document: i, Kind: typeMeta.Kind, Field: err.Field}
+					if src != nil {
+						f.Line, f.Column = src.position(err.Field)
+					}
+					reportWarning(t, f, "would be rejected when created after the previous examples of the directory: %v", err)
+				}
+			}
+		})
+		if err != nil {
+			reportError(t, finding{File: path}, "invalid feature gates in %s: %v", directoryConfigFile, err)
+		}
+	})
+	if err != nil {
+		t.Errorf("Expected no error, Got %v on Path %v", err, examplesRoot)
+	}
+	var dirs []string
+	for dir := range directories {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		objects := directories[dir]
+		for _, problem := range stores[dir].missingReferences(objects) {
+			o := objects[problem.From]
+			f := finding{File: o.File, Document: o.Document, Kind: o.Kind, Field: problem.Field.String()}
+			if src := fileSourceDocument(o.File, o.Document); src != nil {
+				f.Line, f.Column = src.position(f.Field)
+			}
+			reportError(t, f, "%s: %s", f.Field, problem.Message)
+		}
+	}
+}
+
+func TestObjectStorePut(t *testing.T) {
+	pod := func(namespace, image string) *api.Pod {
+		p := &api.Pod{}
+		p.Name = "nginx"
+		p.Namespace = namespace
+		p.Spec.Containers = []api.Container{{Name: "nginx", Image: image}}
+		return p
+	}
+	namespace := &api.Namespace{}
+	namespace.Name = "test"
+
+	podKind := schema.GroupVersionKind{Version: "v1", Kind: "Pod"}
+	store := newObjectStore(map[objectKey]bool{{"Namespace", "", "created-by-page"}: true})
+	steps := []struct {
+		kind     schema.GroupVersionKind
+		obj      runtime.Object
+		expected []string
+	}{
+		{podKind, pod("test", "nginx:1.7.9"), []string{"metadata.namespace"}},
+		{schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, namespace, nil},
+		{podKind, pod("test", "nginx:1.7.9"), nil},
+		// The image of a pod can be updated.
+		{podKind, pod("test", "nginx:1.8"), nil},
+		{podKind, pod("", "nginx:1.8"), nil},
+		{podKind, pod("created-by-page", "nginx:1.8"), nil},
+	}
+	for i, step := range steps {
+		var actual []string
+		for _, err := range store.put(step.kind, step.obj) {
+			actual = append(actual, err.Field)
+		}
+		if fmt.Sprint(actual) != fmt.Sprint(step.expected) {
+			t.Errorf("step %d: expected errors for %v, got %v", i, step.expected, actual)
+		}
+	}
+
+	// Other fields of a pod are immutable.
+	changed := pod("", "nginx:1.8")
+	changed.Spec.Containers[0].Name = "web"
+	if errs := store.put(podKind, changed); len(errs) == 0 {
+		t.Errorf("Expected an error when changing the container name of a pod")
+	}
+	// The rejected update is not stored.
+	if errs := store.put(podKind, pod("", "nginx:1.9")); len(errs) != 0 {
+		t.Errorf("Expected the pod to be updated from the stored version, got %v", errs)
+	}
+}
+
+func TestObjectStoreMissingReferences(t *testing.T) {
+	configMap := &api.ConfigMap{}
+	configMap.Name = "config"
+	configMap.Data = map[string]string{"game.properties": ""}
+	optional := true
+
+	pod := &api.Pod{}
+	pod.Name = "app"
+	pod.Spec.ServiceAccountName = "app"
+	pod.Spec.Volumes = []api.Volume{
+		{Name: "config", VolumeSource: api.VolumeSource{ConfigMap: &api.ConfigMapVolumeSource{
+			LocalObjectReference: api.LocalObjectReference{Name: "config"},
+			Items:                []api.KeyToPath{{Key: "game.properties"}, {Key: "ui.properties"}},
+		}}},
+		{Name: "optional", VolumeSource: api.VolumeSource{Secret: &api.SecretVolumeSource{SecretName: "optional", Optional: &optional}}},
+		{Name: "data", VolumeSource: api.VolumeSource{PersistentVolumeClaim: &api.PersistentVolumeClaimVolumeSource{ClaimName: "data"}}},
+	}
+	pod.Spec.Containers = []api.Container{{
+		Name:    "app",
+		EnvFrom: []api.EnvFromSource{{ConfigMapRef: &api.ConfigMapEnvSource{LocalObjectReference: api.LocalObjectReference{Name: "env"}}}},
+		Env: []api.EnvVar{{Name: "PASSWORD", ValueFrom: &api.EnvVarSource{SecretKeyRef: &api.SecretKeySelector{
+			LocalObjectReference: api.LocalObjectReference{Name: "created-by-page"},
+			Key:                  "password",
+		}}}},
+	}}
+
+	store := newObjectStore(map[objectKey]bool{{"Secret", "", "created-by-page"}: true})
+	for _, step := range []struct {
+		kind string
+		obj  runtime.Object
+	}{{"ConfigMap", configMap}, {"Pod", pod}} {
+		if errs := store.put(schema.GroupVersionKind{Version: "v1", Kind: step.kind}, step.obj); len(errs) > 0 {
+			t.Fatalf("Unexpected errors storing a %s: %v", step.kind, errs)
+		}
+	}
+	var actual []string
+	for _, problem := range store.missingReferences([]exampleObject{{"test.yaml", 0, "Pod", pod}}) {
+		actual = append(actual, problem.Field.String())
+	}
+	expected := []string{
+		"spec.volumes[0].configMap.items[1].key",
+		"spec.volumes[2].persistentVolumeClaim.claimName",
+		"spec.containers[0].envFrom[0].configMapRef.name",
+		"spec.serviceAccountName",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected problems with %v, got %v", expected, actual)
+	}
+}
+
+func TestPageObjects(t *testing.T) {
+	dir, err := ioutil.TempDir("", "page-objects")
+	if err != nil {
+		t.Fatalf("Unable to create a directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	page := filepath.Join(dir, "page.md")
+	markdown := "```shell\nkubectl create secret generic db-user-pass --from-file=./username.txt\nkubectl  create configmap\ngame-config --from-file=configure-pod-container/\n```\n\n" +
+		"```yaml\napiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: build-robot\n```\n"
+	if err := ioutil.WriteFile(page, []byte(markdown), 0644); err != nil {
+		t.Fatalf("Unable to write %s: %v", page, err)
+	}
+	actual, err := pageObjects([]string{page})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[objectKey]bool{
+		{"Secret", "", "db-user-pass"}:        true,
+		{"ConfigMap", "", "game-config"}:      true,
+		{"ServiceAccount", "", "build-robot"}: true,
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
@@ -0,0 +1,393 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package examples_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextensionsinstall "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/install"
+	apiextensions_validation "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/validation"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apiserver/pkg/storage/names"
+	"k8s.io/kubernetes/pkg/api/legacyscheme"
+	"k8s.io/kubernetes/pkg/apis/admissionregistration"
+	ar_validation "k8s.io/kubernetes/pkg/apis/admissionregistration/validation"
+	"k8s.io/kubernetes/pkg/apis/apps"
+	apps_validation "k8s.io/kubernetes/pkg/apis/apps/validation"
+	"k8s.io/kubernetes/pkg/apis/authentication"
+	"k8s.io/kubernetes/pkg/apis/authorization"
+	authorization_validation "k8s.io/kubernetes/pkg/apis/authorization/validation"
+	"k8s.io/kubernetes/pkg/apis/autoscaling"
+	autoscaling_validation "k8s.io/kubernetes/pkg/apis/autoscaling/validation"
+	"k8s.io/kubernetes/pkg/apis/batch"
+	batch_validation "k8s.io/kubernetes/pkg/apis/batch/validation"
+	"k8s.io/kubernetes/pkg/apis/certificates"
+	certificates_validation "k8s.io/kubernetes/pkg/apis/certificates/validation"
+	api "k8s.io/kubernetes/pkg/apis/core"
+	"k8s.io/kubernetes/pkg/apis/core/validation"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	ext_validation "k8s.io/kubernetes/pkg/apis/extensions/validation"
+	"k8s.io/kubernetes/pkg/apis/imagepolicy"
+	"k8s.io/kubernetes/pkg/apis/networking"
+	networking_validation "k8s.io/kubernetes/pkg/apis/networking/validation"
+	"k8s.io/kubernetes/pkg/apis/policy"
+	policy_validation "k8s.io/kubernetes/pkg/apis/policy/validation"
+	"k8s.io/kubernetes/pkg/apis/rbac"
+	rbac_validation "k8s.io/kubernetes/pkg/apis/rbac/validation"
+	"k8s.io/kubernetes/pkg/apis/scheduling"
+	scheduling_validation "k8s.io/kubernetes/pkg/apis/scheduling/validation"
+	"k8s.io/kubernetes/pkg/apis/settings"
+	settings_validation "k8s.io/kubernetes/pkg/apis/settings/validation"
+	"k8s.io/kubernetes/pkg/apis/storage"
+	storage_validation "k8s.io/kubernetes/pkg/apis/storage/validation"
+	"k8s.io/kubernetes/pkg/registry/batch/job"
+)
+
+// objectValidator validates the internal objects of one group and kind.
+type objectValidator struct {
+	// Namespaced objects without a namespace are validated in the default
+	// namespace, where kubectl creates them.
+	Namespaced bool
+	Validate   func(obj runtime.Object) field.ErrorList
+}
+
+// objectValidators are the validators of all supported kinds, see
+// registerValidator.
+var objectValidators = map[schema.GroupKind]objectValidator{}
+
+// registerValidator registers validate for the internal objects of gk. Kinds
+// that are served by several groups, e.g. extensions and apps, only need to
+// be registered once.
+func registerValidator(gk schema.GroupKind, namespaced bool, validate func(obj runtime.Object) field.ErrorList) {
+	if _, found := objectValidators[gk]; found {
+		panic(fmt.Sprintf("validator for %v registered twice", gk))
+	}
+	objectValidators[gk] = objectValidator{namespaced, validate}
+}
+
+// notValidated is registered for kinds that the API server does not validate,
+// e.g. reviews whose spec is only interpreted by a webhook.
+func notValidated(obj runtime.Object) field.ErrorList {
+	return nil
+}
+
+// findValidator returns the validator registered for one of the kinds of obj.
+func findValidator(obj runtime.Object) (objectValidator, schema.GroupKind, error) {
+	kinds, _, err := legacyscheme.Scheme.ObjectKinds(obj)
+	if err != nil {
+		return objectValidator{}, schema.GroupKind{}, err
+	}
+	for _, kind := range kinds {
+		if validator, found := objectValidators[kind.GroupKind()]; found {
+			return validator, kind.GroupKind(), nil
+		}
+	}
+	return objectValidator{}, kinds[0].GroupKind(), fmt.Errorf("no validation defined for %v", kinds[0].GroupKind())
+}
+
+func validateObject(obj runtime.Object) field.ErrorList {
+	validator, _, err := findValidator(obj)
+	if err != nil {
+		return field.ErrorList{field.InternalError(field.NewPath(""), err)}
+	}
+	if accessor, err := meta.Accessor(obj); err == nil {
+		if validator.Namespaced && accessor.GetNamespace() == "" {
+			accessor.SetNamespace(api.NamespaceDefault)
+		}
+		// The API server generates the name before validating the object.
+		if accessor.GetName() == "" && accessor.GetGenerateName() != "" {
+			accessor.SetName(names.SimpleNameGenerator.GenerateName(accessor.GetGenerateName()))
+		}
+	}
+	return validator.Validate(obj)
+}
+
+// validateItems validates the items of a list, with paths relative to the
+// list.
+func validateItems(items []runtime.Object) field.ErrorList {
+	var errors field.ErrorList
+	for i, item := range items {
+		if unknown, ok := item.(*runtime.Unknown); ok {
+			// The items of a List are not decoded with it.
+			obj, err := decodeDocument(unknown.Raw)
+			if err != nil {
+				errors = append(errors, field.Invalid(field.NewPath("items").Index(i), "", err.Error()))
+				continue
+			}
+			item = obj
+		}
+		for _, err := range validateObject(item) {
+			err.Field = field.NewPath("items").Index(i).String() + "." + err.Field
+			errors = append(errors, err)
+		}
+	}
+	return errors
+}
+
+func init() {
+	apiextensionsinstall.Install(legacyscheme.GroupFactoryRegistry, legacyscheme.Registry, legacyscheme.Scheme)
+
+	registerValidator(admissionregistration.Kind("InitializerConfiguration"), false, func(obj runtime.Object) field.ErrorList {
+		return ar_validation.ValidateInitializerConfiguration(obj.(*admissionregistration.InitializerConfiguration))
+	})
+	registerValidator(admissionregistration.Kind("MutatingWebhookConfiguration"), false, func(obj runtime.Object) field.ErrorList {
+		return ar_validation.ValidateMutatingWebhookConfiguration(obj.(*admissionregistration.MutatingWebhookConfiguration))
+	})
+	registerValidator(admissionregistration.Kind("ValidatingWebhookConfiguration"), false, func(obj runtime.Object) field.ErrorList {
+		return ar_validation.ValidateValidatingWebhookConfiguration(obj.(*admissionregistration.ValidatingWebhookConfiguration))
+	})
+
+	registerValidator(apiextensions.Kind("CustomResourceDefinition"), false, func(obj runtime.Object) field.ErrorList {
+		return apiextensions_validation.ValidateCustomResourceDefinition(obj.(*apiextensions.CustomResourceDefinition))
+	})
+
+	registerValidator(apps.Kind("StatefulSet"), true, func(obj runtime.Object) field.ErrorList {
+		return apps_validation.ValidateStatefulSet(obj.(*apps.StatefulSet))
+	})
+
+	registerValidator(authentication.Kind("TokenReview"), false, notValidated)
+
+	registerValidator(authorization.Kind("LocalSubjectAccessReview"), true, func(obj runtime.Object) field.ErrorList {
+		return authorization_validation.ValidateLocalSubjectAccessReview(obj.(*authorization.LocalSubjectAccessReview))
+	})
+	registerValidator(authorization.Kind("SelfSubjectAccessReview"), false, func(obj runtime.Object) field.ErrorList {
+		return authorization_validation.ValidateSelfSubjectAccessReview(obj.(*authorization.SelfSubjectAccessReview))
+	})
+	registerValidator(authorization.Kind("SelfSubjectRulesReview"), false, func(obj runtime.Object) field.ErrorList {
+		return authorization_validation.ValidateSelfSubjectRulesReview(obj.(*authorization.SelfSubjectRulesReview))
+	})
+	// The SubjectAccessReviews in the docs are the bodies exchanged with an
+	// authorization webhook, which are not validated by the API server.
+	registerValidator(authorization.Kind("SubjectAccessReview"), false, notValidated)
+
+	registerValidator(autoscaling.Kind("HorizontalPodAutoscaler"), true, func(obj runtime.Object) field.ErrorList {
+		return autoscaling_validation.ValidateHorizontalPodAutoscaler(obj.(*autoscaling.HorizontalPodAutoscaler))
+	})
+
+	registerValidator(batch.Kind("CronJob"), true, func(obj runtime.Object) field.ErrorList {
+		return batch_validation.ValidateCronJob(obj.(*batch.CronJob))
+	})
+	registerValidator(batch.Kind("Job"), true, func(obj runtime.Object) field.ErrorList {
+		t := obj.(*batch.Job)
+		// Job needs generateSelector called before validation, and job.Validate does this.
+		// See: https://github.com/kubernetes/kubernetes/issues/20951#issuecomment-187787040
+		t.ObjectMeta.UID = types.UID("fakeuid")
+		if strings.Index(t.ObjectMeta.Name, "$") > -1 {
+			t.ObjectMeta.Name = "skip-for-good"
+		}
+		return job.Strategy.Validate(nil, t)
+	})
+
+	registerValidator(certificates.Kind("CertificateSigningRequest"), false, func(obj runtime.Object) field.ErrorList {
+		return certificates_validation.ValidateCertificateSigningRequest(obj.(*certificates.CertificateSigningRequest))
+	})
+
+	registerValidator(api.Kind("ConfigMap"), true, func(obj runtime.Object) field.ErrorList {
+		return validation.ValidateConfigMap(obj.(*api.ConfigMap))
+	})
+	registerValidator(api.Kind("Endpoints"), true, func(obj runtime.Object) field.ErrorList {
+		return validation.ValidateEndpoints(obj.(*api.Endpoints))
+	})
+	registerValidator(api.Kind("LimitRange"), true, func(obj runtime.Object) field.ErrorList {
+		return validation.ValidateLimitRange(obj.(*api.LimitRange))
+	})
+	registerValidator(api.Kind("List"), false, func(obj runtime.Object) field.ErrorList {
+		return validateItems(obj.(*api.List).Items)
+	})
+	registerValidator(api.Kind("Namespace"), false, func(obj runtime.Object) field.ErrorList {
+		return validation.ValidateNamespace(obj.(*api.Namespace))
+	})
+	registerValidator(api.Kind("Node"), false, func(obj runtime.Object) field.ErrorList {
+		return validation.ValidateNode(obj.(*api.Node))
+	})
+	registerValidator(api.Kind("PersistentVolume"), false, func(obj runtime.Object) field.ErrorList {
+		return validation.ValidatePersistentVolume(obj.(*api.PersistentVolume))
+	})
+	registerValidator(api.Kind("PersistentVolumeClaim"), true, func(obj runtime.Object) field.ErrorList {
+		return validation.ValidatePersistentVolumeClaim(obj.(*api.PersistentVolumeClaim))
+	})
+	registerValidator(api.Kind("Pod"), true, func(obj runtime.Object) field.ErrorList {
+		return validation.ValidatePod(obj.(*api.Pod))
+	})
+	registerValidator(api.Kind("PodList"), false, func(obj runtime.Object) field.ErrorList {
+		var items []runtime.Object
+		for i := range obj.(*api.PodList).Items {
+			items = append(items, &obj.(*api.PodList).Items[i])
+		}
+		return validateItems(items)
+	})
+	registerValidator(api.Kind("PodTemplate"), true, func(obj runtime.Object) field.ErrorList {
+		return validation.ValidatePodTemplate(obj.(*api.PodTemplate))
+	})
+	registerValidator(api.Kind("ReplicationController"), true, func(obj runtime.Object) field.ErrorList {
+		return validation.ValidateReplicationController(obj.(*api.ReplicationController))
+	})
+	registerValidator(api.Kind("ReplicationControllerList"), false, func(obj runtime.Object) field.ErrorList {
+		var items []runtime.Object
+		for i := range obj.(*api.ReplicationControllerList).Items {
+			items = append(items, &obj.(*api.ReplicationControllerList).Items[i])
+		}
+		return validateItems(items)
+	})
+	registerValidator(api.Kind("ResourceQuota"), true, func(obj runtime.Object) field.ErrorList {
+		return validation.ValidateResourceQuota(obj.(*api.ResourceQuota))
+	})
+	registerValidator(api.Kind("Secret"), true, func(obj runtime.Object) field.ErrorList {
+		return validation.ValidateSecret(obj.(*api.Secret))
+	})
+	registerValidator(api.Kind("Service"), true, func(obj runtime.Object) field.ErrorList {
+		return validation.ValidateService(obj.(*api.Service))
+	})
+	registerValidator(api.Kind("ServiceAccount"), true, func(obj runtime.Object) field.ErrorList {
+		return validation.ValidateServiceAccount(obj.(*api.ServiceAccount))
+	})
+	registerValidator(api.Kind("ServiceList"), false, func(obj runtime.Object) field.ErrorList {
+		var items []runtime.Object
+		for i := range obj.(*api.ServiceList).Items {
+			items = append(items, &obj.(*api.ServiceList).Items[i])
+		}
+		return validateItems(items)
+	})
+
+	registerValidator(extensions.Kind("DaemonSet"), true, func(obj runtime.Object) field.ErrorList {
+		return ext_validation.ValidateDaemonSet(obj.(*extensions.DaemonSet))
+	})
+	registerValidator(extensions.Kind("Deployment"), true, func(obj runtime.Object) field.ErrorList {
+		return ext_validation.ValidateDeployment(obj.(*extensions.Deployment))
+	})
+	registerValidator(extensions.Kind("Ingress"), true, func(obj runtime.Object) field.ErrorList {
+		return ext_validation.ValidateIngress(obj.(*extensions.Ingress))
+	})
+	registerValidator(extensions.Kind("PodSecurityPolicy"), false, func(obj runtime.Object) field.ErrorList {
+		return ext_validation.ValidatePodSecurityPolicy(obj.(*extensions.PodSecurityPolicy))
+	})
+	registerValidator(extensions.Kind("ReplicaSet"), true, func(obj runtime.Object) field.ErrorList {
+		return ext_validation.ValidateReplicaSet(obj.(*extensions.ReplicaSet))
+	})
+
+	registerValidator(imagepolicy.Kind("ImageReview"), false, notValidated)
+
+	registerValidator(networking.Kind("NetworkPolicy"), true, func(obj runtime.Object) field.ErrorList {
+		return networking_validation.ValidateNetworkPolicy(obj.(*networking.NetworkPolicy))
+	})
+
+	registerValidator(policy.Kind("Eviction"), true, notValidated)
+	registerValidator(policy.Kind("PodDisruptionBudget"), true, func(obj runtime.Object) field.ErrorList {
+		return policy_validation.ValidatePodDisruptionBudget(obj.(*policy.PodDisruptionBudget))
+	})
+
+	registerValidator(rbac.Kind("ClusterRole"), false, func(obj runtime.Object) field.ErrorList {
+		return rbac_validation.ValidateClusterRole(obj.(*rbac.ClusterRole))
+	})
+	registerValidator(rbac.Kind("ClusterRoleBinding"), false, func(obj runtime.Object) field.ErrorList {
+		return rbac_validation.ValidateClusterRoleBinding(obj.(*rbac.ClusterRoleBinding))
+	})
+	registerValidator(rbac.Kind("Role"), true, func(obj runtime.Object) field.ErrorList {
+		return rbac_validation.ValidateRole(obj.(*rbac.Role))
+	})
+	registerValidator(rbac.Kind("RoleBinding"), true, func(obj runtime.Object) field.ErrorList {
+		return rbac_validation.ValidateRoleBinding(obj.(*rbac.RoleBinding))
+	})
+
+	registerValidator(scheduling.Kind("PriorityClass"), false, func(obj runtime.Object) field.ErrorList {
+		return scheduling_validation.ValidatePriorityClass(obj.(*scheduling.PriorityClass))
+	})
+
+	registerValidator(settings.Kind("PodPreset"), true, func(obj runtime.Object) field.ErrorList {
+		return settings_validation.ValidatePodPreset(obj.(*settings.PodPreset))
+	})
+
+	registerValidator(storage.Kind("StorageClass"), false, func(obj runtime.Object) field.ErrorList {
+		return storage_validation.ValidateStorageClass(obj.(*storage.StorageClass))
+	})
+}
+
+// TestValidatorCoverage verifies that a validator is registered for every
+// kind of API object used in the examples and in the code blocks of the
+// Markdown pages.
+func TestValidatorCoverage(t *testing.T) {
+	used := map[schema.GroupKind][]string{}
+	addDocuments := func(location string, docs [][]byte) {
+		for _, data := range docs {
+			typeMeta, err := documentTypeMeta(data)
+			if err != nil || typeMeta.APIVersion == "" || typeMeta.Kind == "" {
+				continue
+			}
+			gvk := schema.FromAPIVersionAndKind(typeMeta.APIVersion, typeMeta.Kind)
+			if !legacyscheme.Scheme.Recognizes(gvk) {
+				continue
+			}
+			obj, err := decodeDocument(data)
+			if err != nil {
+				continue
+			}
+			if _, gk, err := findValidator(obj); err != nil {
+				used[gk] = append(used[gk], location)
+			}
+		}
+	}
+	err := walkConfigFiles(examplesRoot, func(name, path string, docs [][]byte, config *directoryConfig) {
+		addDocuments(path, docs)
+	})
+	if err != nil {
+		t.Errorf("Expected no error, Got %v on Path %v", err, examplesRoot)
+	}
+	for _, root := range markdownRoots {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() || filepath.Ext(path) != ".md" {
+				return err
+			}
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			for _, block := range extractCodeBlocks(data) {
+				if block.SkipReason != "" || subsetRegexp.MatchString(block.Content) {
+					continue
+				}
+				docs, err := splitDocuments([]byte(block.Content))
+				if err != nil {
+					continue
+				}
+				addDocuments(fmt.Sprintf("%s:%d", path, block.Line), docs)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Errorf("Expected no error, Got %v on Path %v", err, root)
+		}
+	}
+	var missing []string
+	for gk, locations := range used {
+		missing = append(missing, fmt.Sprintf("%v (used in %s)", gk, strings.Join(locations, ", ")))
+	}
+	sort.Strings(missing)
+	for _, m := range missing {
+		t.Errorf("No validator registered for %s, see registerValidator", m)
+	}
+}
@@ -0,0 +1,270 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package examples_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"text/tabwriter"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/kube-openapi/pkg/util/proto/validation"
+	"k8s.io/kubernetes/pkg/kubectl/cmd/util/openapi"
+	openapitesting "k8s.io/kubernetes/pkg/kubectl/cmd/util/openapi/testing"
+)
+
+// schemasDir holds one trimmed OpenAPI document per Kubernetes release,
+// named after the release, e.g. v1.10.json.
+const schemasDir = "openapi"
+
+// siteConfigFile is the Jekyll configuration of the website.
+const siteConfigFile = "../_config.yml"
+
+var schemaVersions = flag.String("schema-versions", "", "Comma separated list of Kubernetes versions, e.g. v1.9,v1.10, to validate examples against. Defaults to all versions in "+schemasDir+".")
+
+// minorVersion is a Kubernetes release such as v1.10.
+type minorVersion struct {
+	Major, Minor int
+}
+
+func parseMinorVersion(s string) (minorVersion, error) {
+	parts := strings.Split(strings.TrimPrefix(s, "v"), ".")
+	if len(parts) < 2 {
+		return minorVersion{}, fmt.Errorf("invalid version %q, expected vMAJOR.MINOR", s)
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return minorVersion{}, fmt.Errorf("invalid version %q: %v", s, err)
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return minorVersion{}, fmt.Errorf("invalid version %q: %v", s, err)
+	}
+	return minorVersion{major, minor}, nil
+}
+
+func (v minorVersion) String() string {
+	return fmt.Sprintf("v%d.%d", v.Major, v.Minor)
+}
+
+func (v minorVersion) Less(other minorVersion) bool {
+	if v.Major != other.Major {
+		return v.Major < other.Major
+	}
+	return v.Minor < other.Minor
+}
+
+// siteLatestVersion returns the "latest" Kubernetes version the website
+// documents, as configured in siteConfigFile.
+func siteLatestVersion() (minorVersion, error) {
+	data, err := ioutil.ReadFile(siteConfigFile)
+	if err != nil {
+		return minorVersion{}, err
+	}
+	out, err := yaml.ToJSON(data)
+	if err != nil {
+		return minorVersion{}, fmt.Errorf("%s: %v", siteConfigFile, err)
+	}
+	var config struct {
+		Latest string `json:"latest"`
+	}
+	if err := json.Unmarshal(out, &config); err != nil {
+		return minorVersion{}, fmt.Errorf("%s: %v", siteConfigFile, err)
+	}
+	return parseMinorVersion(config.Latest)
+}
+
+// versionedSchema is the OpenAPI schema of one Kubernetes release.
+type versionedSchema struct {
+	Version   minorVersion
+	Resources openapi.Resources
+}
+
+// loadSchemas loads the OpenAPI schemas of the given versions from dir, or of
+// every version found in dir if versions is empty. The result is sorted from
+// the oldest to the newest version.
+func loadSchemas(dir string, versions []string) ([]versionedSchema, error) {
+	if len(versions) == 0 {
+		files, err := filepath.Glob(filepath.Join(dir, "v*.json"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			versions = append(versions, strings.TrimSuffix(filepath.Base(file), ".json"))
+		}
+	}
+
+	var schemas []versionedSchema
+	for _, v := range versions {
+		version, err := parseMinorVersion(strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
+		}
+		fake := &openapitesting.Fake{Path: filepath.Join(dir, version.String()+".json")}
+		doc, err := fake.OpenAPISchema()
+		if err != nil {
+			return nil, fmt.Errorf("schema for %s: %v", version, err)
+		}
+		resources, err := openapi.NewOpenAPIData(doc)
+		if err != nil {
+			return nil, fmt.Errorf("schema for %s: %v", version, err)
+		}
+		schemas = append(schemas, versionedSchema{version, resources})
+	}
+	sort.Slice(schemas, func(i, j int) bool {
+		return schemas[i].Version.Less(schemas[j].Version)
+	})
+	return schemas, nil
+}
+
+// validateAgainstSchema validates the JSON document in data against the schema
+// of one release. A kind that the release does not serve is an error.
+func validateAgainstSchema(s versionedSchema, data []byte) []error {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return []error{err}
+	}
+	apiVersion, _ := obj["apiVersion"].(string)
+	kind, _ := obj["kind"].(string)
+	gvk := schema.FromAPIVersionAndKind(apiVersion, kind)
+	resource := s.Resources.LookupResource(gvk)
+	if resource == nil {
+		return []error{fmt.Errorf("%s is not served by %s", gvk, s.Version)}
+	}
+	return validation.ValidateModel(obj, resource, kind)
+}
+
+// exampleMinVersion returns the oldest release that the example at path must
+// be valid for, given the configuration of its directory, or latest if the
+// configuration sets none.
+func exampleMinVersion(config *directoryConfig, path string, latest minorVersion) (minorVersion, error) {
+	v := config.example(filepath.Base(path)).MinVersion
+	if v == "" {
+		return latest, nil
+	}
+	return parseMinorVersion(v)
+}
+
+// TestExampleSchemaVersions validates every example against the OpenAPI
+// schemas of several Kubernetes releases and reports which of them accept
+// each document (run with -v to see the report). An example must be accepted
+// by every release starting with its minimum version, which defaults to the
+// latest version of the website and can be lowered with minVersion in
+// directoryConfigFile.
+func TestExampleSchemaVersions(t *testing.T) {
+	var versions []string
+	if *schemaVersions != "" {
+		versions = strings.Split(*schemaVersions, ",")
+	}
+	schemas, err := loadSchemas(schemasDir, versions)
+	if err != nil {
+		t.Fatalf("Unable to load schemas: %v", err)
+	}
+	if len(schemas) == 0 {
+		t.Fatalf("No schemas found in %s", schemasDir)
+	}
+	latest, err := siteLatestVersion()
+	if err != nil {
+		t.Fatalf("Unable to determine the latest version: %v", err)
+	}
+
+	var report bytes.Buffer
+	w := tabwriter.NewWriter(&report, 0, 4, 2, ' ', 0)
+	fmt.Fprint(w, "EXAMPLE\tKIND")
+	for _, s := range schemas {
+		fmt.Fprintf(w, "\t%s", s.Version)
+	}
+	fmt.Fprintln(w)
+
+	err = walkConfigFiles(examplesRoot, func(_, path string, docs [][]byte, config *directoryConfig) {
+		minVersion, err := exampleMinVersion(config, path, latest)
+		if err != nil {
+			reportError(t, finding{File: path}, "invalid minVersion in %s: %v", directoryConfigFile, err)
+			return
+		}
+		for i, data := range docs {
+			typeMeta, err := documentTypeMeta(data)
+			if err != nil || typeMeta.Kind == "" {
+				continue
+			}
+			if _, found := findComponentConfig(typeMeta); found {
+				// Not part of the API, so not in its schemas.
+				continue
+			}
+			fmt.Fprintf(w, "%s[%d]\t%s", path, i, typeMeta.Kind)
+			for _, s := range schemas {
+				errs := validateAgainstSchema(s, data)
+				if len(errs) == 0 {
+					fmt.Fprint(w, "\tok")
+					continue
+				}
+				fmt.Fprint(w, "\trejected")
+				if !s.Version.Less(minVersion) {
+					f := finding{File: path, Document: i, Kind: typeMeta.Kind}
+					reportError(t, f, "requires %s or later but does not validate against %s: %v", minVersion, s.Version, errs)
+				}
+			}
+			fmt.Fprintln(w)
+		}
+	})
+	if err != nil {
+		t.Errorf("Expected no error, Got %v on Path %v", err, examplesRoot)
+	}
+	w.Flush()
+	t.Logf("Validation of examples against Kubernetes releases:\n%s", report.String())
+}
+
+func TestExampleMinVersion(t *testing.T) {
+	latest := minorVersion{1, 10}
+	config := &directoryConfig{
+		MinVersion: "v1.9",
+		Files: map[string]exampleConfig{
+			"pod.yaml":     {MinVersion: "v1.8"},
+			"invalid.yaml": {MinVersion: "1"},
+		},
+	}
+	cases := []struct {
+		config   *directoryConfig
+		path     string
+		expected minorVersion
+		err      bool
+	}{
+		{&directoryConfig{}, "docs/pod.yaml", latest, false},
+		{config, "docs/pod.yaml", minorVersion{1, 8}, false},
+		{config, "docs/service.yaml", minorVersion{1, 9}, false},
+		{config, "docs/invalid.yaml", minorVersion{}, true},
+	}
+	for _, c := range cases {
+		actual, err := exampleMinVersion(c.config, c.path, latest)
+		if (err != nil) != c.err {
+			t.Errorf("%s: expected error %t, got %v", c.path, c.err, err)
+			continue
+		}
+		if actual != c.expected {
+			t.Errorf("%s: expected %s, got %s", c.path, c.expected, actual)
+		}
+	}
+}